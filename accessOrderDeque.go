@@ -41,14 +41,15 @@ func (q *AccessOrderDeque) PushFront(n *Node) {
 	if q.Contains(n) {
 		return
 	}
+	links := n.link()
 	h := q.head
 	q.head = n
 	if h == nil {
 		// the deque is empty
 		q.tail = n
 	} else {
-		h.prev = n
-		n.next = h
+		h.links.prev = n
+		links.next = h
 	}
 }
 
@@ -56,13 +57,14 @@ func (q *AccessOrderDeque) PushBack(n *Node) {
 	if q.Contains(n) {
 		return
 	}
+	links := n.link()
 	t := q.tail
 	q.tail = n
 	if t == nil {
 		q.head = n
 	} else {
-		t.next = n
-		n.prev = t
+		t.links.next = n
+		links.prev = t
 	}
 }
 
@@ -81,31 +83,31 @@ func (q *AccessOrderDeque) GetBack() *Node {
 }
 
 func (q *AccessOrderDeque) GetPrevious(n *Node) *Node {
-	return n.prev
+	return n.prevNode()
 }
 
 func (q *AccessOrderDeque) GetNext(n *Node) *Node {
-	return n.next
+	return n.nextNode()
 }
 
 func (q *AccessOrderDeque) Remove(n *Node) bool {
 	if !q.Contains(n) {
 		return false
 	}
-	prev := n.prev
-	next := n.next
+	prev := n.prevNode()
+	next := n.nextNode()
 	if prev == nil {
-		q.head = n
+		q.head = next
 	} else {
-		prev.next = next
-		n.prev = nil
+		prev.links.next = next
+		n.links.prev = nil
 	}
 
 	if next == nil {
-		q.tail = n
+		q.tail = prev
 	} else {
-		next.prev = prev
-		n.next = nil
+		next.links.prev = prev
+		n.links.next = nil
 	}
 
 	return true
@@ -116,8 +118,7 @@ func (q *AccessOrderDeque) RemoveFront() *Node {
 		return nil
 	}
 	h := q.head
-	next := h.next
-	h.next = nil
+	next := h.nextNode()
 	q.head = next
 
 	// only one node in deque
@@ -125,7 +126,8 @@ func (q *AccessOrderDeque) RemoveFront() *Node {
 		q.tail = nil
 	} else {
 		// multi node in deque
-		next.prev = nil
+		h.links.next = nil
+		next.links.prev = nil
 	}
 	return h
 }
@@ -135,14 +137,14 @@ func (q *AccessOrderDeque) RemoveBack() *Node {
 		return nil
 	}
 	t := q.tail
-	prev := t.prev
-	t.prev = nil
+	prev := t.prevNode()
 	q.tail = prev
 
 	if prev == nil {
 		q.head = nil
 	} else {
-		prev.next = nil
+		t.links.prev = nil
+		prev.links.next = nil
 	}
 	return t
 }
@@ -171,9 +173,11 @@ func (q *AccessOrderDeque) MoveToBack(n *Node) {
 
 func (q *AccessOrderDeque) Clear() {
 	for cur := q.head; cur != nil; {
-		next := cur.next
-		cur.prev = nil
-		cur.next = nil
+		next := cur.nextNode()
+		if cur.links != nil {
+			cur.links.prev = nil
+			cur.links.next = nil
+		}
 		cur = next
 	}
 	q.head = nil
@@ -183,7 +187,7 @@ func (q *AccessOrderDeque) Clear() {
 func (q *AccessOrderDeque) ToSlice() []*Node {
 	size := q.Size()
 	ret := make([]*Node, size, size)
-	for cur := q.head; cur != nil; cur = cur.next {
+	for cur := q.head; cur != nil; cur = cur.nextNode() {
 		ret = append(ret, cur)
 	}
 	return ret
@@ -194,12 +198,12 @@ func (q *AccessOrderDeque) IsEmpty() bool {
 }
 
 func (q *AccessOrderDeque) Contains(n *Node) bool {
-	return n.prev != nil || n.next != nil || n == q.head
+	return n.prevNode() != nil || n.nextNode() != nil || n == q.head
 }
 
 func (q *AccessOrderDeque) Size() int {
 	n := 0
-	for cur := q.head; cur != nil; cur = cur.next {
+	for cur := q.head; cur != nil; cur = cur.nextNode() {
 		n++
 	}
 	return n
@@ -0,0 +1,87 @@
+package cocoa
+
+import (
+	"math/rand"
+)
+
+// AdmissionPolicy determines if a candidate evicted from the window space is admitted into the main space,
+// evicting the victim of the main space instead.
+// The methods are called by the maintenance under the eviction lock.
+type AdmissionPolicy interface {
+	// Record records an access of the key.
+	Record(key []byte)
+	// Admit returns if the candidate should be admitted and the victim evicted.
+	Admit(candidate, victim *Node) bool
+}
+
+// tinyLFUAdmission admits the candidate if it is more frequent than the victim, as estimated by the sketch.
+type tinyLFUAdmission struct {
+	sketch *FrequencySketch
+}
+
+// NewTinyLFUAdmission returns the TinyLFU admission policy recording the frequency in the sketch.
+// It is the default admission policy of the cache, which uses the cache's sketch.
+func NewTinyLFUAdmission(sketch *FrequencySketch) AdmissionPolicy {
+	return &tinyLFUAdmission{sketch: sketch}
+}
+
+func (a *tinyLFUAdmission) Record(key []byte) {
+	a.sketch.increment(key)
+}
+
+// Admit determines if the candidate should be accepted into the main space, as determined by its
+// frequency relative to the victim. A small amount of randomness is used to protect against hash
+// collision attacks, where the victim's frequency is artificially raised so that no new entries
+// are admitted.
+func (a *tinyLFUAdmission) Admit(candidate, victim *Node) bool {
+	candidateFreq := a.sketch.frequency(candidate.Key)
+	victimFreq := a.sketch.frequency(victim.Key)
+	if candidateFreq > victimFreq {
+		return true
+	} else if candidateFreq <= 5 {
+		// candidateFreq<=victimFreq && candidateFreq <= 5
+		return false
+	}
+	random := rand.Int()
+	return (random & 127) == 0
+}
+
+// alwaysAdmission admits every candidate, so the cache is a pure segmented LRU.
+type alwaysAdmission struct{}
+
+// AlwaysAdmission returns the admission policy admitting every candidate.
+func AlwaysAdmission() AdmissionPolicy {
+	return alwaysAdmission{}
+}
+
+func (alwaysAdmission) Record(key []byte) {}
+
+func (alwaysAdmission) Admit(candidate, victim *Node) bool {
+	return true
+}
+
+// sizeAwareAdmission rejects the candidates heavier than the maximum weight, and delegates the others.
+type sizeAwareAdmission struct {
+	maxWeight int
+	delegate  AdmissionPolicy
+}
+
+// NewSizeAwareAdmission returns the admission policy rejecting the candidates whose weight exceeds the
+// maxWeight, the other candidates are admitted by the delegate, or always admitted if it is nil.
+func NewSizeAwareAdmission(maxWeight int, delegate AdmissionPolicy) AdmissionPolicy {
+	if delegate == nil {
+		delegate = AlwaysAdmission()
+	}
+	return &sizeAwareAdmission{maxWeight: maxWeight, delegate: delegate}
+}
+
+func (a *sizeAwareAdmission) Record(key []byte) {
+	a.delegate.Record(key)
+}
+
+func (a *sizeAwareAdmission) Admit(candidate, victim *Node) bool {
+	if candidate.weight > a.maxWeight {
+		return false
+	}
+	return a.delegate.Admit(candidate, victim)
+}
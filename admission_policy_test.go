@@ -0,0 +1,105 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestAdmissionPolicy(t *testing.T) {
+	t.Run("tinyLFU", func(t *testing.T) {
+		a := NewTinyLFUAdmission(NewFrequencySketch(64))
+		hot := &Node{Key: []byte("hot"), weight: 1}
+		cold := &Node{Key: []byte("cold"), weight: 1}
+		for i := 0; i < 3; i++ {
+			a.Record(hot.Key)
+		}
+		a.Record(cold.Key)
+		assert.True(t, a.Admit(hot, cold))
+		assert.False(t, a.Admit(cold, hot))
+	})
+
+	t.Run("always", func(t *testing.T) {
+		a := AlwaysAdmission()
+		assert.True(t, a.Admit(&Node{Key: []byte("a")}, &Node{Key: []byte("b")}))
+	})
+
+	t.Run("size aware", func(t *testing.T) {
+		a := NewSizeAwareAdmission(10, nil)
+		victim := &Node{Key: []byte("victim"), weight: 1}
+		assert.True(t, a.Admit(&Node{Key: []byte("small"), weight: 10}, victim))
+		assert.False(t, a.Admit(&Node{Key: []byte("large"), weight: 11}, victim))
+
+		tinyLFU := NewSizeAwareAdmission(10, NewTinyLFUAdmission(NewFrequencySketch(64)))
+		tinyLFU.Record(victim.Key)
+		assert.False(t, tinyLFU.Admit(&Node{Key: []byte("small"), weight: 1}, victim))
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		assert.True(t, c.sketchAdmission)
+
+		c = NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).
+			Admission(AlwaysAdmission()).Build()
+		// the sketch is still recorded without the TinyLFU admission
+		c.Put([]byte("k"), 1)
+		c.performCleanUp(nil)
+		assert.Equal(t, 1, c.sketch.frequency([]byte("k")))
+	})
+}
+
+func TestBoundedLocalCache_weigher(t *testing.T) {
+	t.Run("maximum weight", func(t *testing.T) {
+		weigher := func(key []byte, value interface{}) int {
+			return value.(int)
+		}
+		c := NewCacheBuilder().MaximumWeight(100).Weigher(weigher).Executor(NewCallerRunsExecutor()).Build()
+		for i := 0; i < 50; i++ {
+			c.Put([]byte(strconv.Itoa(i)), 5)
+		}
+		c.performCleanUp(nil)
+		assert.True(t, c.weightedSize <= 100)
+		assert.Equal(t, c.weightedSize, 5*c.Size())
+
+		// replacing the value applies the new weight
+		key := []byte("k")
+		c.Put(key, 1)
+		c.performCleanUp(nil)
+		before := c.weightedSize
+		c.Put(key, 3)
+		c.performCleanUp(nil)
+		node, _ := c.data.Get(key)
+		assert.Equal(t, 3, node.weight)
+		assert.True(t, c.weightedSize <= before+2)
+		// the sketch grew with the number of entries
+		assert.True(t, len(c.sketch.table) >= c.Size()/2)
+	})
+
+	t.Run("size aware admission", func(t *testing.T) {
+		weigher := func(key []byte, value interface{}) int {
+			return len(value.(string))
+		}
+		large := []byte("large")
+		for _, admission := range []AdmissionPolicy{AlwaysAdmission(), NewSizeAwareAdmission(20, nil)} {
+			c := NewCacheBuilder().MaximumWeight(200).Weigher(weigher).Executor(NewCallerRunsExecutor()).
+				Admission(admission).Build()
+			for i := 0; i < 200; i++ {
+				c.Put([]byte(strconv.Itoa(i)), "v")
+			}
+			c.performCleanUp(nil)
+			c.Put(large, string(make([]byte, 30)))
+			c.performCleanUp(nil)
+			_, sizeAware := admission.(*sizeAwareAdmission)
+			assert.Equal(t, !sizeAware, c.Contains(large))
+		}
+	})
+
+	t.Run("illegal", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewCacheBuilder().MaximumSize(100).Weigher(func(key []byte, value interface{}) int { return 1 }).Build()
+		})
+		assert.Panics(t, func() {
+			NewCacheBuilder().MaximumWeight(100).Build()
+		})
+	})
+}
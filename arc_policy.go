@@ -0,0 +1,183 @@
+package cocoa
+
+const (
+	// The T1 list of the ARC policy reuses the Probation type of the node, the T2 list reuses the Protected type.
+	arcT1 = Probation
+	arcT2 = Protected
+)
+
+// arcPolicy is the Adaptive Replacement Cache. The T1 list holds the entries accessed once recently and the
+// T2 list holds the entries accessed at least twice recently, both in LRU order. The ghost lists B1 and B2
+// keep the keys evicted from T1 and T2, a new entry found in a ghost list adapts the target weight of T1,
+// and is added to T2.
+type arcPolicy struct {
+	maximum int
+
+	t1, t2             *AccessOrderDeque
+	t1Weight, t2Weight int
+	// the target weight of T1
+	target int
+
+	b1, b2 *ghostList
+	// if the last added entry was found in B2, which evicts from T1 when T1 is at its target
+	hitB2 bool
+}
+
+func newARCPolicy(maximum int) *arcPolicy {
+	return &arcPolicy{
+		maximum: maximum,
+		t1:      &AccessOrderDeque{},
+		t2:      &AccessOrderDeque{},
+		b1:      newGhostList(),
+		b2:      newGhostList(),
+	}
+}
+
+func (p *arcPolicy) OnAdd(n *Node) {
+	p.hitB2 = false
+	switch {
+	case p.b1.contains(n.Key):
+		// T1 was too small, grow its target
+		delta := 1
+		if p.b2.len() > p.b1.len() {
+			delta = p.b2.len() / p.b1.len()
+		}
+		p.target = minInt(p.target+delta*n.weight, p.maximum)
+		p.b1.remove(n.Key)
+		p.pushT2(n)
+	case p.b2.contains(n.Key):
+		// T2 was too small, shrink the target of T1
+		delta := 1
+		if p.b1.len() > p.b2.len() {
+			delta = p.b1.len() / p.b2.len()
+		}
+		p.target = maxInt(p.target-delta*n.weight, 0)
+		p.b2.remove(n.Key)
+		p.hitB2 = true
+		p.pushT2(n)
+	default:
+		n.makeIn(arcT1)
+		p.t1.PushBack(n)
+		p.t1Weight += n.weight
+	}
+}
+
+func (p *arcPolicy) OnAccess(n *Node) {
+	if n.queueType() == arcT1 && p.t1.Contains(n) {
+		p.t1.Remove(n)
+		p.t1Weight -= n.weight
+		p.pushT2(n)
+	} else if n.queueType() == arcT2 && p.t2.Contains(n) {
+		p.t2.MoveToBack(n)
+	}
+}
+
+func (p *arcPolicy) OnUpdate(n *Node, weightDiff int) {
+	if n.queueType() == arcT1 && p.t1.Contains(n) {
+		p.t1Weight += weightDiff
+	} else if n.queueType() == arcT2 && p.t2.Contains(n) {
+		p.t2Weight += weightDiff
+	}
+}
+
+func (p *arcPolicy) OnRemove(n *Node) {
+	if n.queueType() == arcT1 && p.t1.Contains(n) {
+		p.t1.Remove(n)
+		p.t1Weight -= n.weight
+	} else if n.queueType() == arcT2 && p.t2.Contains(n) {
+		p.t2.Remove(n)
+		p.t2Weight -= n.weight
+	}
+}
+
+// Evict replaces the LRU entry of T1 if T1 exceeds its target, otherwise the LRU entry of T2, and keeps
+// the key of the evicted entry in the ghost list.
+func (p *arcPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() {
+		t1Victim := p.t1.GetFront()
+		t2Victim := p.t2.GetFront()
+		if t1Victim == nil && t2Victim == nil {
+			return
+		}
+		victim, ghosts := t2Victim, p.b2
+		if t1Victim != nil && (t2Victim == nil || p.t1Weight > p.target || (p.hitB2 && p.t1Weight == p.target)) {
+			victim, ghosts = t1Victim, p.b1
+		}
+		// stop rather than spin if the evict failed to remove the node
+		if victim == previous {
+			return
+		}
+		previous = victim
+		ghosts.add(victim.Key)
+		evict(victim)
+		p.trimGhosts()
+	}
+}
+
+func (p *arcPolicy) accessOrderDeques() []*AccessOrderDeque {
+	return []*AccessOrderDeque{p.t1, p.t2}
+}
+
+func (p *arcPolicy) pushT2(n *Node) {
+	n.makeIn(arcT2)
+	p.t2.PushBack(n)
+	p.t2Weight += n.weight
+}
+
+// trimGhosts bounds the ghost lists by the maximum, dropping from B1 while T1 and B1 exceed the maximum.
+func (p *arcPolicy) trimGhosts() {
+	for p.b1.len()+p.b2.len() > p.maximum {
+		if p.b2.len() == 0 || (p.b1.len() > 0 && p.t1Weight+p.b1.len() > p.maximum) {
+			p.b1.removeOldest()
+		} else {
+			p.b2.removeOldest()
+		}
+	}
+}
+
+// ghostList is an LRU list of the keys of the non-resident entries, the keys are kept in nodes without value.
+type ghostList struct {
+	deque *AccessOrderDeque
+	index map[string]*Node
+}
+
+func newGhostList() *ghostList {
+	return &ghostList{deque: &AccessOrderDeque{}, index: make(map[string]*Node)}
+}
+
+func (g *ghostList) len() int {
+	return len(g.index)
+}
+
+func (g *ghostList) add(key []byte) {
+	if ghost, ok := g.index[*bytesToString(key)]; ok {
+		g.deque.MoveToBack(ghost)
+		return
+	}
+	ghost := &Node{Key: key}
+	g.index[string(key)] = ghost
+	g.deque.PushBack(ghost)
+}
+
+func (g *ghostList) contains(key []byte) bool {
+	_, ok := g.index[*bytesToString(key)]
+	return ok
+}
+
+// remove removes the key, and returns if it was in the list.
+func (g *ghostList) remove(key []byte) bool {
+	ghost, ok := g.index[*bytesToString(key)]
+	if !ok {
+		return false
+	}
+	delete(g.index, string(key))
+	g.deque.Remove(ghost)
+	return true
+}
+
+func (g *ghostList) removeOldest() {
+	if ghost := g.deque.RemoveFront(); ghost != nil {
+		delete(g.index, string(ghost.Key))
+	}
+}
@@ -0,0 +1,57 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestARCPolicy(t *testing.T) {
+	t.Run("scan resistance", func(t *testing.T) {
+		c := policyCacheForTest(ARCPolicy, 10)
+		var hot []*Node
+		for i := 0; i < 5; i++ {
+			n := putNodeForTest(c, "hot"+strconv.Itoa(i))
+			c.onAccess(n)
+			hot = append(hot, n)
+		}
+		for i := 0; i < 100; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+			c.evictEntries()
+		}
+		for _, n := range hot {
+			assert.True(t, n.isAlive())
+			assert.Equal(t, arcT2, n.queueType())
+		}
+		assert.Equal(t, 10, c.weightedSize)
+	})
+
+	t.Run("ghost hit adapts the target", func(t *testing.T) {
+		c := policyCacheForTest(ARCPolicy, 4)
+		p := c.policy.(*arcPolicy)
+		for i := 0; i < 5; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+			c.evictEntries()
+		}
+		assert.True(t, p.b1.contains([]byte("0")))
+		assert.Equal(t, 0, p.target)
+
+		n := putNodeForTest(c, "0")
+		assert.Equal(t, arcT2, n.queueType())
+		assert.Equal(t, 1, p.target)
+		assert.False(t, p.b1.contains([]byte("0")))
+		c.evictEntries()
+		assert.Equal(t, 4, p.t1Weight+p.t2Weight)
+	})
+
+	t.Run("ghost lists are bounded", func(t *testing.T) {
+		c := policyCacheForTest(ARCPolicy, 4)
+		p := c.policy.(*arcPolicy)
+		for i := 0; i < 100; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+			c.evictEntries()
+		}
+		assert.True(t, p.b1.len()+p.b2.len() <= 4)
+		assert.Equal(t, p.b1.len(), len(p.b1.index))
+	})
+}
@@ -6,25 +6,17 @@ package cocoa
 // 3. DeleteTask
 // Add/Update/Delete the node to the page replacement policy.
 // This task will not change the cache entity, and only to update the page replacement policy.
+// A task may be stale when it runs, so it must check the node's state and discard
+// the operation if it would resurrect a removed entry.
 type task interface {
 	run()
 }
 
-type ReadTask struct {
-	c    *BoundedLocalCache
-	node *Node
-}
-
-func (t *ReadTask) run() {
-	t.c.onAccess(t.node)
-}
-
 // AddTask is a async task used for add new kv to LocalCache
 // Support size-base eviction
 type AddTask struct {
-	c      *BoundedLocalCache
-	node   *Node
-	weight int
+	c    *BoundedLocalCache
+	node *Node
 }
 
 // AddTask update node's the weight and frequency
@@ -33,22 +25,33 @@ func (t *AddTask) run() {
 	if !c.EnableEvict() {
 		return
 	}
-	// update cache size
-	c.weightedSize += t.weight
-	// update window deque size
-	c.windowWeightedSize += t.weight
-
 	node := t.node
+	// the DeleteTask or the eviction ran before this task, and counted no weight for the node
+	if node.isDead() {
+		return
+	}
+	// update cache size, the DeleteTask of a retired node will subtract it
+	c.weightedSize += node.weight
+	node.accounted = true
+
 	if len(node.Key) != 0 {
-		c.sketch.increment(node.Key)
+		c.recordAccess(node.Key)
 	}
-	// insert to tail
-	c.accessOrderWindowDeque().PushBack(node)
+	// the sketch of a weighted cache grows with the number of entries
+	if c.weigher != nil && c.weightedSize >= c.maximum>>1 {
+		c.sketch.ensureCapacity(c.data.Len())
+	}
+	// the entry was deleted before this task ran, don't link it to the deque
+	if !node.isAlive() {
+		return
+	}
+	c.policy.OnAdd(node)
 }
 
 type UpdateTask struct {
-	c          *BoundedLocalCache
-	node       *Node
+	c    *BoundedLocalCache
+	node *Node
+	// the difference of the new value's weight from the replaced one, computed under the segment lock
 	weightDiff int
 }
 
@@ -59,15 +62,20 @@ func (t *UpdateTask) run() {
 		return
 	}
 	node := t.node
-	if node.inWindow() {
-		c.windowWeightedSize = c.windowWeightedSize + t.weightDiff
-	} else if node.inMainProtected() {
-		c.mainProtectedWeightedSize = c.mainProtectedWeightedSize + t.weightDiff
-	} else if node.inMainProbation() {
-		// will move to protected deque
-		// do nothing
+	// the entry was already evicted or removed, its weight is no longer counted
+	if node.isDead() {
+		return
+	}
+	node.weight += t.weightDiff
+	// the AddTask has not run yet, it will count the new weight
+	if !node.accounted {
+		return
+	}
+	c.weightedSize = c.weightedSize + t.weightDiff
+	c.policy.OnUpdate(node, t.weightDiff)
+	if node.isAlive() {
+		c.onAccess(node)
 	}
-	c.onAccess(node)
 }
 
 type DeleteTask struct {
@@ -82,13 +90,18 @@ func (t *DeleteTask) run() {
 		return
 	}
 	node := t.node
-	if node.inWindow() {
-		c.accessOrderWindowDeque().Remove(node)
-		c.windowWeightedSize -= node.weight
-	} else if node.inMainProbation() {
-		c.accessOrderProbationDeque().Remove(node)
-	} else {
-		c.accessOrderProtectedDeque().Remove(node)
-		c.mainProtectedWeightedSize -= node.weight
+	seg := c.data.getSegment(c.data.hash(node.Key))
+	seg.mux.Lock()
+	if node.isDead() {
+		// the entry was evicted before this task ran
+		seg.mux.Unlock()
+		return
+	}
+	node.die()
+	seg.mux.Unlock()
+
+	if node.accounted {
+		c.weightedSize -= node.weight
 	}
+	c.policy.OnRemove(node)
 }
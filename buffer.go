@@ -5,15 +5,6 @@ import (
 	"unsafe"
 )
 
-const (
-	// The max number of element this buffer could store.
-	bufferSize = 16
-	// 256
-	spaceSize = bufferSize << 4
-	spaceMask = spaceSize - 1
-	offset    = 16
-)
-
 type bufferStatus uint8
 
 const (
@@ -22,94 +13,63 @@ const (
 	failed               = 2
 )
 
-// ringBuffer is the multi producer and one consumer buffer.
-// ringBuffer is a lock-free fixed-size multi-producer,
-// single-consumer queue. The multi producers can offer from the tail.
-// and single consumer can poll from the head.
-type ringBuffer struct {
+const (
+	// The max number of nodes the read buffer could record.
+	readBufferSize = 16
+	readBufferMask = readBufferSize - 1
+)
+
+// readBuffer is a lossy bounded buffer that records the nodes being read.
+// The multi producers offer to the tail, and the single consumer drains from the head.
+// An access event is dropped rather than blocking the reader when the buffer is full
+// or another producer wins the slot, since the page replacement policy tolerates lost reads.
+// The read index and write index are padded to separate cache lines, since the
+// producers update the write index and the consumer updates the read index.
+type readBuffer struct {
 	buf *atomicArray
+	_   [CacheLineSize - PtrSize]byte
 	r   uint32
+	_   [CacheLineSize - 4]byte
 	w   uint32
+	_   [CacheLineSize - 4]byte
 }
 
-func newRingBuffer() *ringBuffer {
-	ret := &ringBuffer{
-		buf: newAtomicArray(spaceSize),
-		r:   0,
-		w:   0,
+func newReadBuffer() *readBuffer {
+	return &readBuffer{
+		buf: newAtomicArray(readBufferSize),
 	}
-	return ret
 }
 
-// offer inserts the pointer of specified element into this buffer if it is possible to do so immediately without
-// violating capacity restrictions. The addition is allowed to fail spuriously if multiple threads insert concurrently.
-func (r *ringBuffer) offer(n unsafe.Pointer) bufferStatus {
-	// read index
-	head := atomic.LoadUint32(&r.r)
-	// write index
-	tail := atomic.LoadUint32(&r.w)
+// offer records the node if there is a free slot and no other producer is contending for it.
+func (b *readBuffer) offer(n *Node) bufferStatus {
+	head := atomic.LoadUint32(&b.r)
+	tail := atomic.LoadUint32(&b.w)
 	size := tail - head
-	if size >= spaceSize {
+	if size >= readBufferSize {
 		return full
 	}
-	if atomic.CompareAndSwapUint32(&r.w, tail, tail+offset) {
-		idx := int(tail & spaceMask)
-		r.buf.set(idx, n)
+	if atomic.CompareAndSwapUint32(&b.w, tail, tail+1) {
+		b.buf.set(int(tail&readBufferMask), unsafe.Pointer(n))
 		return success
 	}
 	return failed
 }
 
-// poll removes and returns the element at the head of buffer. It returns nil if the
-// queue is empty. It must only be called by a single consumer.
-func (r *ringBuffer) poll() task {
-	// read index
-	head := atomic.LoadUint32(&r.r)
-	// write index
-	tail := atomic.LoadUint32(&r.w)
-	size := tail - head
-	if size == 0 {
-		return nil
-	}
-	idx := int(head & spaceMask)
-	e := r.buf.get(idx)
-	if e == nil {
-		// not published yet
-		return nil
-	}
-	r.buf.set(idx, nil)
-	tPtr := (*task)(e)
-	t := *tPtr
-	//t.run()
-	head += offset
-	atomic.StoreUint32(&r.r, head)
-	return t
-}
-
-// Drains the buffer, sending each element to the consumer for processing.
+// drainTo drains the buffer, sending each recorded node to the consumer.
 // The caller must ensure that a consumer has exclusive read access to the buffer.
-func (r *ringBuffer) drainBuf() {
-	// read index
-	head := atomic.LoadUint32(&r.r)
-	// write index
-	tail := atomic.LoadUint32(&r.w)
-	size := tail - head
-	if size == 0 {
-		return
-	}
-
-	for tail != head {
-		idx := int(head & spaceMask)
-		e := r.buf.get(idx)
+func (b *readBuffer) drainTo(consumer func(n *Node)) {
+	head := atomic.LoadUint32(&b.r)
+	tail := atomic.LoadUint32(&b.w)
+	for head != tail {
+		idx := int(head & readBufferMask)
+		e := b.buf.get(idx)
 		if e == nil {
 			// not published yet
 			break
 		}
-		r.buf.set(idx, nil)
-		tPtr := (*task)(e)
-		t := *tPtr
-		t.run()
-		head += offset
+		b.buf.set(idx, nil)
+		consumer((*Node)(e))
+		head++
 	}
-	atomic.StoreUint32(&r.r, head)
+	atomic.StoreUint32(&b.r, head)
 }
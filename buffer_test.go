@@ -1,30 +1,27 @@
 package cocoa
 
 import (
-	"fmt"
 	"github.com/stretchr/testify/assert"
 	"strconv"
 	"testing"
-	"unsafe"
 )
 
-func testElemConsumer(p unsafe.Pointer) {
-	tPtr := (*Test)(p)
-	fmt.Printf("%+v \n", tPtr)
-}
-
-func Test_RingBuffer(t *testing.T) {
-	t.Run("Test_RingBuffer", func(t *testing.T) {
-		buf := newRingBuffer()
-		for i := 0; i < 16; i++ {
-			buf.offer(unsafe.Pointer(&Test{
-				name: "louyuting" + strconv.Itoa(i),
-				age:  i,
-			}))
+func Test_ReadBuffer(t *testing.T) {
+	t.Run("Test_ReadBuffer_drainTo", func(t *testing.T) {
+		buf := newReadBuffer()
+		nodes := make([]*Node, 0, readBufferSize)
+		for i := 0; i < readBufferSize; i++ {
+			n := &Node{Key: []byte("louyuting" + strconv.Itoa(i))}
+			nodes = append(nodes, n)
+			assert.True(t, buf.offer(n) == success)
 		}
-		assert.True(t, buf.offer(unsafe.Pointer(&Test{name: "louyuting", age: 18})) == full)
-		buf.drainBuf()
+		assert.True(t, buf.offer(&Node{}) == full)
 
-		assert.True(t, buf.r == 256 && buf.w == 256)
+		drained := make([]*Node, 0, readBufferSize)
+		buf.drainTo(func(n *Node) {
+			drained = append(drained, n)
+		})
+		assert.Equal(t, nodes, drained)
+		assert.True(t, buf.offer(&Node{}) == success)
 	})
 }
@@ -0,0 +1,209 @@
+package cocoa
+
+import (
+	"time"
+)
+
+// Weigher returns the weight of an entry, which must not be negative. The weight is calculated when the
+// entry is put, and is not changed until the entry is replaced.
+type Weigher func(key []byte, value interface{}) int
+
+// CacheBuilder configures and builds a BoundedLocalCache, e.g.
+//
+//	cache := NewCacheBuilder().MaximumSize(10000).Executor(NewCallerRunsExecutor()).Build()
+type CacheBuilder struct {
+	maximum         int
+	weighted        bool
+	executor        Executor
+	removalListener RemovalListener
+	ticker          Ticker
+
+	expireAfterAccess time.Duration
+	scheduler         Scheduler
+	cleanupInterval   time.Duration
+
+	maxDrainTasks      int
+	maxEvictions       int
+	maxMaintenanceTime time.Duration
+
+	doorkeeper   bool
+	sketchLayout SketchLayout
+
+	sampleMultiplier int
+	decayInterval    time.Duration
+
+	hotKeys int
+
+	curveSampleRate float64
+	curveSamples    int
+
+	distinctKeysWindow time.Duration
+
+	admission AdmissionPolicy
+	weigher   Weigher
+	policy    PolicyType
+
+	evictionSamples int
+}
+
+func NewCacheBuilder() *CacheBuilder {
+	return &CacheBuilder{}
+}
+
+// MaximumSize sets the maximum number of entries the cache may contain.
+func (b *CacheBuilder) MaximumSize(maximum int) *CacheBuilder {
+	b.maximum = maximum
+	return b
+}
+
+// MaximumWeight sets the maximum total weight of the entries the cache may contain, the weights are
+// determined by the Weigher.
+func (b *CacheBuilder) MaximumWeight(maximum int) *CacheBuilder {
+	b.maximum = maximum
+	b.weighted = true
+	return b
+}
+
+// Weigher sets the Weigher of the entries, it must be used with MaximumWeight.
+func (b *CacheBuilder) Weigher(weigher Weigher) *CacheBuilder {
+	b.weigher = weigher
+	return b
+}
+
+// Executor sets the Executor used for the maintenance and the removal notifications.
+// By default the caches share the DefaultExecutor.
+func (b *CacheBuilder) Executor(executor Executor) *CacheBuilder {
+	b.executor = executor
+	return b
+}
+
+// RemovalListener sets the listener notified when an entry is removed from the cache.
+func (b *CacheBuilder) RemovalListener(listener RemovalListener) *CacheBuilder {
+	b.removalListener = listener
+	return b
+}
+
+// Ticker sets the time source of the cache, SystemTicker by default.
+func (b *CacheBuilder) Ticker(ticker Ticker) *CacheBuilder {
+	b.ticker = ticker
+	return b
+}
+
+// ExpireAfterAccess sets the duration after which an entry expires since its last read or write.
+func (b *CacheBuilder) ExpireAfterAccess(d time.Duration) *CacheBuilder {
+	b.expireAfterAccess = d
+	return b
+}
+
+// Scheduler sets the Scheduler used to perform the maintenance when the cache is idle. The maintenance is
+// scheduled at the next expiration deadline, or at the CleanupInterval if it is earlier.
+// Without a Scheduler the maintenance only happens as a side effect of the reads and writes.
+func (b *CacheBuilder) Scheduler(scheduler Scheduler) *CacheBuilder {
+	b.scheduler = scheduler
+	return b
+}
+
+// CleanupInterval sets the maximum interval between two maintenance runs when a Scheduler is set.
+func (b *CacheBuilder) CleanupInterval(d time.Duration) *CacheBuilder {
+	b.cleanupInterval = d
+	return b
+}
+
+// MaxDrainTasks sets the maximum number of write tasks a maintenance run drains, WriteBufferMaxCapacity by default.
+func (b *CacheBuilder) MaxDrainTasks(n int) *CacheBuilder {
+	b.maxDrainTasks = n
+	return b
+}
+
+// MaxEvictionsPerMaintenance sets the maximum number of entries a maintenance run evicts or expires,
+// unlimited by default. The cache may exceed its maximum until the rescheduled runs catch up.
+func (b *CacheBuilder) MaxEvictionsPerMaintenance(n int) *CacheBuilder {
+	b.maxEvictions = n
+	return b
+}
+
+// MaxMaintenanceTime sets the time slice of a maintenance run, unlimited by default.
+// The run yields and reschedules itself once the time slice is used up.
+func (b *CacheBuilder) MaxMaintenanceTime(d time.Duration) *CacheBuilder {
+	b.maxMaintenanceTime = d
+	return b
+}
+
+// Doorkeeper enables the Bloom filter in front of the frequency sketch, so the keys seen only once
+// don't pollute the sketch's counters. It suits the workloads with mostly unique keys.
+func (b *CacheBuilder) Doorkeeper(enabled bool) *CacheBuilder {
+	b.doorkeeper = enabled
+	return b
+}
+
+// SketchLayout sets the table layout of the frequency sketch, ScatteredLayout by default.
+func (b *CacheBuilder) SketchLayout(layout SketchLayout) *CacheBuilder {
+	b.sketchLayout = layout
+	return b
+}
+
+// SketchSampleMultiplier sets when the frequency sketch ages: the counters are halved once the number of
+// recorded accesses reaches the multiplier times of the maximum size, 10 by default.
+// A smaller multiplier makes the frequency reflect the more recent popularity.
+func (b *CacheBuilder) SketchSampleMultiplier(multiplier int) *CacheBuilder {
+	b.sampleMultiplier = multiplier
+	return b
+}
+
+// SketchDecayInterval makes the frequency sketch age by time instead of the sample size: the counters
+// are halved every interval of the ticker time.
+func (b *CacheBuilder) SketchDecayInterval(interval time.Duration) *CacheBuilder {
+	b.decayInterval = interval
+	return b
+}
+
+// TrackHotKeys enables the tracking of the given number of hottest keys, which are returned by HotKeys.
+func (b *CacheBuilder) TrackHotKeys(capacity int) *CacheBuilder {
+	b.hotKeys = capacity
+	return b
+}
+
+// TrackHitRateCurve enables the estimation of the hit rates of the other cache sizes, which are returned by
+// HitRateCurve. The keys read and written are sampled by hash at the sampleRate in (0, 1], e.g. 0.01, and
+// at most maxSamples keys are tracked, 8192 if not positive, by lowering the rate.
+func (b *CacheBuilder) TrackHitRateCurve(sampleRate float64, maxSamples int) *CacheBuilder {
+	b.curveSampleRate = sampleRate
+	b.curveSamples = maxSamples
+	return b
+}
+
+// DistinctKeysWindow sets the window of EstimatedDistinctKeys, one minute by default.
+func (b *CacheBuilder) DistinctKeysWindow(window time.Duration) *CacheBuilder {
+	b.distinctKeysWindow = window
+	return b
+}
+
+// Admission sets the AdmissionPolicy of the main space, TinyLFU on the cache's sketch by default.
+func (b *CacheBuilder) Admission(policy AdmissionPolicy) *CacheBuilder {
+	b.admission = policy
+	return b
+}
+
+// EvictionPolicy sets the EvictionPolicy of the cache, WTinyLFUPolicy by default. The AdmissionPolicy is
+// only used by the WTinyLFUPolicy.
+func (b *CacheBuilder) EvictionPolicy(policy PolicyType) *CacheBuilder {
+	b.policy = policy
+	return b
+}
+
+// EvictionSamples sets the number of entries sampled for an eviction by the SampledLRUPolicy, 5 by default.
+// More samples approximate the LRU better at a higher cost.
+func (b *CacheBuilder) EvictionSamples(samples int) *CacheBuilder {
+	b.evictionSamples = samples
+	return b
+}
+
+func (b *CacheBuilder) Build() *BoundedLocalCache {
+	if b.maximum <= 0 {
+		panic("maximum must be positive.")
+	}
+	if b.weighted != (b.weigher != nil) {
+		panic("weigher must be used with maximum weight.")
+	}
+	return newBoundedLocalCache(b)
+}
@@ -0,0 +1,337 @@
+// Command simulator replays a key access trace or a synthetic workload through the eviction policies of the
+// cache, and reports the hit rate, the byte hit rate and the evictions of each policy and cache size, e.g.
+//
+//	simulator -trace trace.txt.gz -trace-format arc -policies W-TinyLFU,LRU,ARC -sizes 1000,10000 -format csv
+//	simulator -workload zipfian -items 100000 -requests 1000000 -mix 90,5,5 -sizes 1000,10000
+//
+// The trace formats are those of the trace package, keys by default. A missed read puts the key into the
+// cache, so the trace is replayed through the same code paths as the production cache. An event without a
+// weight weighs 1. The workloads are those of the workload package, the hit rates count the reads only.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/louyuting/cocoa"
+	"github.com/louyuting/cocoa/trace"
+	"github.com/louyuting/cocoa/workload"
+)
+
+// source replays the operations of a trace or a workload to the emit function, from the start on every call.
+// The value size of an operation is its weight.
+type source func(emit func(op workload.Op)) error
+
+// options is the configuration of a simulation by the flags.
+type options struct {
+	tracePath   string
+	traceFormat string
+
+	workload string
+	items    uint64
+	requests int
+	mix      string
+	theta    float64
+	// the mean value size of the workload
+	valueSize int
+	seed      int64
+
+	policies string
+	sizes    string
+	weighted bool
+	format   string
+}
+
+// result is the statistics of replaying a trace through a policy of a size.
+type result struct {
+	policy    cocoa.PolicyType
+	size      int
+	requests  int64
+	hits      int64
+	bytes     int64
+	hitBytes  int64
+	evictions int64
+}
+
+func (r result) hitRate() float64 {
+	if r.requests == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.requests)
+}
+
+func (r result) byteHitRate() float64 {
+	if r.bytes == 0 {
+		return 0
+	}
+	return float64(r.hitBytes) / float64(r.bytes)
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.tracePath, "trace", "", "the path of the trace file, which may be gzip compressed")
+	flag.StringVar(&opts.traceFormat, "trace-format", "keys", "the format of the trace: keys, arc, lirs, wikipedia, cloudphysics or twitter")
+	flag.StringVar(&opts.workload, "workload", "", "the synthetic workload instead of a trace: zipfian, scrambled-zipfian, uniform, hotspot or scan")
+	flag.Uint64Var(&opts.items, "items", 100000, "the number of distinct keys of the workload")
+	flag.IntVar(&opts.requests, "requests", 1000000, "the number of operations of the workload")
+	flag.StringVar(&opts.mix, "mix", "100,0,0", "the read,write,delete ratio of the workload")
+	flag.Float64Var(&opts.theta, "theta", 0.99, "the zipfian constant of the workload, in (0, 1)")
+	flag.IntVar(&opts.valueSize, "value-size", 1, "the mean value size of the workload, exponentially distributed if more than 1")
+	flag.Int64Var(&opts.seed, "seed", 1, "the random seed of the workload")
+	flag.StringVar(&opts.policies, "policies", "W-TinyLFU,LRU", "the comma separated policies, or all")
+	flag.StringVar(&opts.sizes, "sizes", "1000", "the comma separated cache sizes")
+	flag.BoolVar(&opts.weighted, "weighted", false, "bound the cache by the total weight of the entries instead of the count")
+	flag.StringVar(&opts.format, "format", "table", "the output format, table or csv")
+	flag.Parse()
+
+	if err := run(opts, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "simulator:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, out io.Writer) error {
+	policies, err := parsePolicies(opts.policies)
+	if err != nil {
+		return err
+	}
+	sizes, err := parseSizes(opts.sizes)
+	if err != nil {
+		return err
+	}
+	var src source
+	switch {
+	case opts.tracePath != "" && opts.workload != "":
+		return fmt.Errorf("either the trace or the workload is required, not both")
+	case opts.tracePath != "":
+		eventFormat, err := trace.ParseFormat(opts.traceFormat)
+		if err != nil {
+			return err
+		}
+		src = fileSource(opts.tracePath, eventFormat)
+	case opts.workload != "":
+		if src, err = workloadSource(opts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("the trace or the workload is required")
+	}
+
+	var results []result
+	for _, size := range sizes {
+		for _, policy := range policies {
+			r, err := simulate(src, policy, size, opts.weighted)
+			if err != nil {
+				return err
+			}
+			results = append(results, r)
+		}
+	}
+
+	switch opts.format {
+	case "table":
+		return writeTable(out, results)
+	case "csv":
+		return writeCSV(out, results)
+	default:
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+}
+
+// simulate replays the trace through a cache of the policy and the size.
+func simulate(src source, policy cocoa.PolicyType, size int, weighted bool) (result, error) {
+	r := result{policy: policy, size: size}
+	builder := cocoa.NewCacheBuilder().
+		EvictionPolicy(policy).
+		// the maintenance and the notifications run on the replaying goroutine
+		Executor(cocoa.NewCallerRunsExecutor()).
+		RemovalListener(func(key []byte, value interface{}, cause cocoa.RemovalCause) {
+			if cause == cocoa.Evicted {
+				r.evictions++
+			}
+		})
+	if weighted {
+		builder.MaximumWeight(size).Weigher(func(key []byte, value interface{}) int {
+			return value.(int)
+		})
+	} else {
+		builder.MaximumSize(size)
+	}
+	cache := builder.Build()
+
+	err := src(func(op workload.Op) {
+		switch op.Type {
+		case workload.Read:
+			r.requests++
+			r.bytes += int64(op.ValueSize)
+			if cache.Get(op.Key) != nil {
+				r.hits++
+				r.hitBytes += int64(op.ValueSize)
+				return
+			}
+			cache.Put(op.Key, op.ValueSize)
+		case workload.Write:
+			cache.Put(op.Key, op.ValueSize)
+		case workload.Delete:
+			cache.Delete(op.Key)
+		}
+	})
+	cache.CleanUp()
+	return r, err
+}
+
+// fileSource returns the source reading the trace file in the format.
+func fileSource(path string, format trace.Format) source {
+	return func(emit func(op workload.Op)) error {
+		r, err := trace.Open(path, format)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return trace.ForEach(r, func(e trace.Event) {
+			weight := e.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			emit(workload.Op{Type: workload.Read, Key: e.Key, ValueSize: weight})
+		})
+	}
+}
+
+// workloadSource returns the source generating the requests of the workload by the options, the same
+// operations on every call by the seed.
+func workloadSource(opts options) (source, error) {
+	if opts.items == 0 || opts.requests <= 0 {
+		return nil, fmt.Errorf("illegal items %d or requests %d", opts.items, opts.requests)
+	}
+	if opts.theta <= 0 || opts.theta >= 1 {
+		return nil, fmt.Errorf("illegal theta %v", opts.theta)
+	}
+	mix, err := parseMix(opts.mix)
+	if err != nil {
+		return nil, err
+	}
+	var newKeys func(r *rand.Rand) workload.KeyGenerator
+	switch opts.workload {
+	case "zipfian":
+		newKeys = func(r *rand.Rand) workload.KeyGenerator { return workload.NewZipfian(r, opts.items, opts.theta) }
+	case "scrambled-zipfian":
+		newKeys = func(r *rand.Rand) workload.KeyGenerator {
+			return workload.NewScrambledZipfian(r, opts.items, opts.theta)
+		}
+	case "uniform":
+		newKeys = func(r *rand.Rand) workload.KeyGenerator { return workload.NewUniform(r, opts.items) }
+	case "hotspot":
+		// 20% of the keys take 80% of the operations
+		newKeys = func(r *rand.Rand) workload.KeyGenerator { return workload.NewHotspot(r, opts.items, 0.2, 0.8) }
+	case "scan":
+		newKeys = func(r *rand.Rand) workload.KeyGenerator { return workload.NewLoopingScan(opts.items) }
+	default:
+		return nil, fmt.Errorf("unknown workload %q", opts.workload)
+	}
+
+	return func(emit func(op workload.Op)) error {
+		r := rand.New(rand.NewSource(opts.seed))
+		sizes := workload.NewConstantSize(1)
+		if opts.valueSize > 1 {
+			sizes = workload.NewExponentialSize(r, opts.valueSize)
+		}
+		w := workload.New(r, newKeys(r), mix, sizes)
+		for i := 0; i < opts.requests; i++ {
+			emit(w.Next())
+		}
+		return nil
+	}, nil
+}
+
+// parseMix parses the comma separated read, write and delete ratios.
+func parseMix(s string) (workload.Mix, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return workload.Mix{}, fmt.Errorf("illegal mix %q", s)
+	}
+	var ratios [3]float64
+	for i, part := range parts {
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || ratio < 0 {
+			return workload.Mix{}, fmt.Errorf("illegal mix %q", s)
+		}
+		ratios[i] = ratio
+	}
+	if ratios[0]+ratios[1]+ratios[2] == 0 {
+		return workload.Mix{}, fmt.Errorf("illegal mix %q", s)
+	}
+	return workload.Mix{Read: ratios[0], Write: ratios[1], Delete: ratios[2]}, nil
+}
+
+// parsePolicies parses the comma separated policy names, all means every policy.
+func parsePolicies(names string) ([]cocoa.PolicyType, error) {
+	var all []cocoa.PolicyType
+	for policy := cocoa.WTinyLFUPolicy; policy.String() != "Unknown"; policy++ {
+		all = append(all, policy)
+	}
+	if names == "all" {
+		return all, nil
+	}
+	var policies []cocoa.PolicyType
+	for _, name := range strings.Split(names, ",") {
+		found := false
+		for _, policy := range all {
+			if strings.EqualFold(policy.String(), strings.TrimSpace(name)) {
+				policies = append(policies, policy)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown policy %q", name)
+		}
+	}
+	return policies, nil
+}
+
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, s := range strings.Split(list, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("illegal size %q", s)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func writeTable(out io.Writer, results []result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Policy\tSize\tRequests\tHit Rate\tByte Hit Rate\tEvictions\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%.2f%%\t%d\t\n",
+			r.policy, r.size, r.requests, 100*r.hitRate(), 100*r.byteHitRate(), r.evictions)
+	}
+	return w.Flush()
+}
+
+func writeCSV(out io.Writer, results []result) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"policy", "size", "requests", "hit_rate", "byte_hit_rate", "evictions"})
+	for _, r := range results {
+		w.Write([]string{
+			r.policy.String(),
+			strconv.Itoa(r.size),
+			strconv.FormatInt(r.requests, 10),
+			strconv.FormatFloat(r.hitRate(), 'f', 4, 64),
+			strconv.FormatFloat(r.byteHitRate(), 'f', 4, 64),
+			strconv.FormatInt(r.evictions, 10),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
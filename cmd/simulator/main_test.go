@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"github.com/louyuting/cocoa"
+	"github.com/louyuting/cocoa/workload"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	t.Run("counts", func(t *testing.T) {
+		src := func(emit func(op workload.Op)) error {
+			for _, e := range []struct {
+				key    string
+				weight int
+			}{{"a", 10}, {"b", 30}, {"a", 10}, {"c", 1}, {"a", 10}} {
+				emit(workload.Op{Type: workload.Read, Key: []byte(e.key), ValueSize: e.weight})
+			}
+			return nil
+		}
+		r, err := simulate(src, cocoa.LRUPolicy, 10, false)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), r.requests)
+		assert.Equal(t, int64(2), r.hits)
+		assert.Equal(t, int64(61), r.bytes)
+		assert.Equal(t, int64(20), r.hitBytes)
+		assert.Equal(t, int64(0), r.evictions)
+	})
+
+	t.Run("evictions", func(t *testing.T) {
+		src := func(emit func(op workload.Op)) error {
+			for i := 0; i < 100; i++ {
+				emit(workload.Op{Type: workload.Read, Key: []byte(strconv.Itoa(i)), ValueSize: 1})
+			}
+			return nil
+		}
+		r, err := simulate(src, cocoa.FIFOPolicy, 10, false)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), r.hits)
+		assert.Equal(t, int64(90), r.evictions)
+	})
+
+	t.Run("writes and deletes", func(t *testing.T) {
+		src := func(emit func(op workload.Op)) error {
+			emit(workload.Op{Type: workload.Write, Key: []byte("a"), ValueSize: 1})
+			emit(workload.Op{Type: workload.Read, Key: []byte("a"), ValueSize: 1})
+			emit(workload.Op{Type: workload.Delete, Key: []byte("a")})
+			emit(workload.Op{Type: workload.Read, Key: []byte("a"), ValueSize: 1})
+			return nil
+		}
+		r, err := simulate(src, cocoa.LRUPolicy, 10, false)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), r.requests)
+		assert.Equal(t, int64(1), r.hits)
+	})
+}
+
+func TestRun(t *testing.T) {
+	f, err := ioutil.TempFile("", "trace")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	for i := 0; i < 1000; i++ {
+		f.WriteString(strconv.Itoa(i%50) + "\n")
+	}
+	f.Close()
+
+	opts := func(traceFormat, policies, sizes, format string) options {
+		return options{tracePath: f.Name(), traceFormat: traceFormat, policies: policies, sizes: sizes, format: format}
+	}
+	var out bytes.Buffer
+	assert.Nil(t, run(opts("keys", "lru,ARC", "10,100", "csv"), &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, "policy,size,requests,hit_rate,byte_hit_rate,evictions", lines[0])
+	assert.Equal(t, "LRU,100,1000,0.9500,0.9500,0", lines[3])
+
+	out.Reset()
+	assert.Nil(t, run(opts("keys", "all", "10", "table"), &out))
+	assert.True(t, strings.Contains(out.String(), "W-TinyLFU"))
+	assert.True(t, strings.Contains(out.String(), "LIRS"))
+
+	assert.NotNil(t, run(opts("keys", "MRU", "10", "table"), &out))
+	assert.NotNil(t, run(opts("keys", "LRU", "0", "table"), &out))
+	assert.NotNil(t, run(opts("keys", "LRU", "10", "xml"), &out))
+	assert.NotNil(t, run(opts("csv", "LRU", "10", "table"), &out))
+	assert.NotNil(t, run(options{policies: "LRU", sizes: "10", format: "table"}, &out))
+}
+
+func TestRun_workload(t *testing.T) {
+	opts := options{
+		workload: "zipfian", items: 1000, requests: 10000, mix: "90,5,5", theta: 0.99, valueSize: 1, seed: 1,
+		policies: "LRU", sizes: "100", format: "csv",
+	}
+	var out bytes.Buffer
+	assert.Nil(t, run(opts, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.True(t, strings.HasPrefix(lines[1], "LRU,100,"))
+
+	// the same seed replays the same operations
+	var again bytes.Buffer
+	assert.Nil(t, run(opts, &again))
+	assert.Equal(t, out.String(), again.String())
+
+	for _, name := range []string{"scrambled-zipfian", "uniform", "hotspot", "scan"} {
+		opts.workload = name
+		assert.Nil(t, run(opts, &out), name)
+	}
+
+	opts.workload = "gaussian"
+	assert.NotNil(t, run(opts, &out))
+	opts.workload = "zipfian"
+	opts.mix = "1,2"
+	assert.NotNil(t, run(opts, &out))
+	opts.mix = "0,0,0"
+	assert.NotNil(t, run(opts, &out))
+	opts.mix = "1,0,0"
+	opts.theta = 1
+	assert.NotNil(t, run(opts, &out))
+	opts.theta = 0.99
+	opts.tracePath = "trace.txt"
+	assert.NotNil(t, run(opts, &out))
+}
@@ -0,0 +1,215 @@
+package cocoa
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// CountMinSketch is a standalone Count-Min sketch with 4-bit counters, the same as the cache uses for
+// its admission policy. It estimates the number of occurrences of a key up to 15, and halves all of
+// the counters once the number of increments reaches 10 times of the capacity, so that the estimate
+// reflects the recent popularity.
+// CountMinSketch is not safe for concurrent use, see ConcurrentCountMinSketch.
+type CountMinSketch struct {
+	sketch *FrequencySketch
+}
+
+// NewCountMinSketch returns the sketch sized for the capacity number of distinct keys.
+func NewCountMinSketch(capacity int) *CountMinSketch {
+	return &CountMinSketch{sketch: NewFrequencySketch(capacity)}
+}
+
+// Increment records an occurrence of the key.
+func (s *CountMinSketch) Increment(key []byte) {
+	s.sketch.increment(key)
+}
+
+// Estimate returns the estimated number of occurrences of the key, up to the maximum (15).
+func (s *CountMinSketch) Estimate(key []byte) int {
+	return s.sketch.frequency(key)
+}
+
+// Reset clears all of the counters.
+func (s *CountMinSketch) Reset() {
+	for i := range s.sketch.table {
+		s.sketch.table[i] = 0
+	}
+	s.sketch.size = 0
+}
+
+// Merge adds the counters of the other sketch to this sketch, the counters saturate at the maximum.
+// Both sketches must have the same capacity.
+func (s *CountMinSketch) Merge(other *CountMinSketch) error {
+	if err := checkMergeable(s.sketch, other.sketch); err != nil {
+		return err
+	}
+	for i := range s.sketch.table {
+		s.sketch.table[i] = addCounters(s.sketch.table[i], other.sketch.table[i])
+	}
+	s.sketch.size += other.sketch.size
+	for s.sketch.size >= s.sketch.sampleSize {
+		s.sketch.reset()
+	}
+	return nil
+}
+
+func (s *CountMinSketch) MarshalBinary() ([]byte, error) {
+	return s.sketch.marshalBinary(), nil
+}
+
+func (s *CountMinSketch) UnmarshalBinary(data []byte) error {
+	if s.sketch == nil {
+		s.sketch = &FrequencySketch{}
+	}
+	return s.sketch.unmarshalBinary(data)
+}
+
+func checkMergeable(f, other *FrequencySketch) error {
+	if len(f.table) != len(other.table) || f.layout != other.layout {
+		return errors.New("cocoa: cannot merge sketches of different capacities or layouts")
+	}
+	if f.seed != other.seed {
+		return errors.New("cocoa: cannot merge sketches of different hash seeds")
+	}
+	return nil
+}
+
+// ConcurrentCountMinSketch is a CountMinSketch safe for concurrent use. The counters are updated by
+// atomic compare-and-swap on the table words, so the increments are lock-free. The halving of the
+// counters is performed by the goroutine whose increment reaches the sample size, and may interleave
+// with the concurrent increments.
+type ConcurrentCountMinSketch struct {
+	sketch *FrequencySketch
+}
+
+// NewConcurrentCountMinSketch returns the sketch sized for the capacity number of distinct keys.
+func NewConcurrentCountMinSketch(capacity int) *ConcurrentCountMinSketch {
+	return &ConcurrentCountMinSketch{sketch: NewFrequencySketch(capacity)}
+}
+
+// Increment records an occurrence of the key.
+func (s *ConcurrentCountMinSketch) Increment(key []byte) {
+	f := s.sketch
+	idx, ordinate := f.counters(f.hash(key))
+	added := false
+	for i := 0; i < 4; i++ {
+		added = s.increaseAt(idx[i], ordinate[i]) || added
+	}
+	if added && atomic.AddUint64(&f.size, 1) == f.sampleSize {
+		s.age()
+	}
+}
+
+// Increments the specified counter by 1 if it is not already at the maximum value (15).
+func (s *ConcurrentCountMinSketch) increaseAt(idx, ordinate int) bool {
+	offset := uint64(ordinate << 2)
+	mask := uint64(0xf << offset)
+	word := &s.sketch.table[idx]
+	for {
+		old := atomic.LoadUint64(word)
+		if old&mask == mask {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(word, old, old+(1<<offset)) {
+			return true
+		}
+	}
+}
+
+// age halves every counter, it is the atomic version of FrequencySketch.reset.
+func (s *ConcurrentCountMinSketch) age() {
+	f := s.sketch
+	count := 0
+	for i := range f.table {
+		word := &f.table[i]
+		for {
+			old := atomic.LoadUint64(word)
+			if atomic.CompareAndSwapUint64(word, old, (old>>1)&ResetMask) {
+				count += bitCount(old & OneMask)
+				break
+			}
+		}
+	}
+	// the size is halved from the observed value rather than the sample size, as the merges and the
+	// concurrent increments may have moved it
+	correction := uint64(count >> 2)
+	for {
+		old := atomic.LoadUint64(&f.size)
+		size := uint64(0)
+		if correction < old>>1 {
+			size = (old >> 1) - correction
+		}
+		if atomic.CompareAndSwapUint64(&f.size, old, size) {
+			return
+		}
+	}
+}
+
+// Estimate returns the estimated number of occurrences of the key, up to the maximum (15).
+func (s *ConcurrentCountMinSketch) Estimate(key []byte) int {
+	f := s.sketch
+	idx, ordinate := f.counters(f.hash(key))
+	frequency := 15
+	for i := 0; i < 4; i++ {
+		count := int((atomic.LoadUint64(&f.table[idx[i]]) >> (ordinate[i] << 2)) & 0xf)
+		if count < frequency {
+			frequency = count
+		}
+	}
+	return frequency
+}
+
+// Reset clears all of the counters.
+func (s *ConcurrentCountMinSketch) Reset() {
+	for i := range s.sketch.table {
+		atomic.StoreUint64(&s.sketch.table[i], 0)
+	}
+	atomic.StoreUint64(&s.sketch.size, 0)
+}
+
+// Merge adds the counters of the other sketch to this sketch, the counters saturate at the maximum.
+// Both sketches must have the same capacity.
+func (s *ConcurrentCountMinSketch) Merge(other *ConcurrentCountMinSketch) error {
+	f := s.sketch
+	if err := checkMergeable(f, other.sketch); err != nil {
+		return err
+	}
+	for i := range f.table {
+		add := atomic.LoadUint64(&other.sketch.table[i])
+		word := &f.table[i]
+		for {
+			old := atomic.LoadUint64(word)
+			if atomic.CompareAndSwapUint64(word, old, addCounters(old, add)) {
+				break
+			}
+		}
+	}
+	atomic.AddUint64(&f.size, atomic.LoadUint64(&other.sketch.size))
+	for atomic.LoadUint64(&f.size) >= f.sampleSize {
+		s.age()
+	}
+	return nil
+}
+
+// MarshalBinary encodes a snapshot of the sketch, the concurrent increments may be partially included.
+func (s *ConcurrentCountMinSketch) MarshalBinary() ([]byte, error) {
+	f := s.sketch
+	snapshot := &FrequencySketch{
+		table:      make([]uint64, len(f.table)),
+		layout:     f.layout,
+		sampleSize: f.sampleSize,
+		size:       atomic.LoadUint64(&f.size),
+	}
+	for i := range f.table {
+		snapshot.table[i] = atomic.LoadUint64(&f.table[i])
+	}
+	return snapshot.marshalBinary(), nil
+}
+
+// UnmarshalBinary decodes the sketch, it must not be called concurrently with the other methods.
+func (s *ConcurrentCountMinSketch) UnmarshalBinary(data []byte) error {
+	if s.sketch == nil {
+		s.sketch = &FrequencySketch{}
+	}
+	return s.sketch.unmarshalBinary(data)
+}
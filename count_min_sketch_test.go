@@ -0,0 +1,182 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	t.Run("TestIncrementAndEstimate", func(t *testing.T) {
+		s := NewCountMinSketch(512)
+		key := []byte("louyuting")
+		assert.Equal(t, 0, s.Estimate(key))
+		for i := 0; i < 5; i++ {
+			s.Increment(key)
+		}
+		assert.Equal(t, 5, s.Estimate(key))
+		s.Reset()
+		assert.Equal(t, 0, s.Estimate(key))
+	})
+
+	t.Run("TestMerge", func(t *testing.T) {
+		s1 := NewCountMinSketch(512)
+		s2 := NewCountMinSketch(512)
+		key := []byte("louyuting")
+		for i := 0; i < 5; i++ {
+			s1.Increment(key)
+		}
+		for i := 0; i < 12; i++ {
+			s2.Increment(key)
+		}
+		s2.Increment([]byte("other"))
+		assert.Nil(t, s1.Merge(s2))
+		assert.Equal(t, 15, s1.Estimate(key))
+		assert.Equal(t, 1, s1.Estimate([]byte("other")))
+
+		assert.NotNil(t, s1.Merge(NewCountMinSketch(1024)))
+	})
+
+	t.Run("TestMarshalBinary", func(t *testing.T) {
+		s := NewCountMinSketch(512)
+		for i := 0; i < 100; i++ {
+			for j := 0; j <= i%10; j++ {
+				s.Increment([]byte(strconv.Itoa(i)))
+			}
+		}
+		data, err := s.MarshalBinary()
+		assert.Nil(t, err)
+
+		restored := &CountMinSketch{}
+		assert.Nil(t, restored.UnmarshalBinary(data))
+		for i := 0; i < 100; i++ {
+			key := []byte(strconv.Itoa(i))
+			assert.Equal(t, s.Estimate(key), restored.Estimate(key))
+		}
+		assert.Equal(t, s.sketch.size, restored.sketch.size)
+		assert.Equal(t, s.sketch.sampleSize, restored.sketch.sampleSize)
+	})
+
+	t.Run("TestUnmarshalBinaryValidation", func(t *testing.T) {
+		data, _ := NewCountMinSketch(16).MarshalBinary()
+		s := &CountMinSketch{}
+		assert.NotNil(t, s.UnmarshalBinary(data[:10]))
+		assert.NotNil(t, s.UnmarshalBinary(data[:len(data)-1]))
+
+		badVersion := append([]byte{}, data...)
+		badVersion[0] = 99
+		assert.NotNil(t, s.UnmarshalBinary(badVersion))
+
+		badLength := append([]byte{}, data...)
+		badLength[29] = 3
+		assert.NotNil(t, s.UnmarshalBinary(badLength))
+
+		zeroSampleSize := append([]byte{}, data...)
+		for i := 10; i < 18; i++ {
+			zeroSampleSize[i] = 0
+		}
+		assert.NotNil(t, s.UnmarshalBinary(zeroSampleSize))
+	})
+
+	t.Run("TestAcrossProcesses", func(t *testing.T) {
+		s := NewCountMinSketch(512)
+		key := []byte("louyuting")
+		s.Increment(key)
+		s.Increment(key)
+		data, _ := s.MarshalBinary()
+
+		// another process seeds the hash of the map differently
+		seeded := hashkey
+		defer func() { hashkey = seeded }()
+		for i := range hashkey {
+			hashkey[i] += 2
+		}
+		restored := &CountMinSketch{}
+		assert.Nil(t, restored.UnmarshalBinary(data))
+		assert.Equal(t, 2, restored.Estimate(key))
+		other := NewCountMinSketch(512)
+		other.Increment(key)
+		assert.Nil(t, other.Merge(restored))
+		assert.Equal(t, 3, other.Estimate(key))
+
+		restored.sketch.seed++
+		assert.NotNil(t, other.Merge(restored))
+		concurrent := NewConcurrentCountMinSketch(512)
+		assert.Nil(t, concurrent.UnmarshalBinary(data))
+		concurrent.sketch.seed++
+		assert.NotNil(t, NewConcurrentCountMinSketch(512).Merge(concurrent))
+	})
+}
+
+func TestConcurrentCountMinSketch(t *testing.T) {
+	t.Run("TestConcurrentIncrement", func(t *testing.T) {
+		s := NewConcurrentCountMinSketch(1024)
+		key := []byte("louyuting")
+		wg := sync.WaitGroup{}
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					s.Increment(key)
+					s.Increment([]byte(strconv.Itoa(i)))
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 15, s.Estimate(key))
+	})
+
+	t.Run("TestAging", func(t *testing.T) {
+		s := NewConcurrentCountMinSketch(16)
+		key := []byte("louyuting")
+		for i := 0; i < 8; i++ {
+			s.Increment(key)
+		}
+		aged := false
+		for i := 0; i < int(s.sketch.sampleSize) && !aged; i++ {
+			s.Increment([]byte(strconv.Itoa(i)))
+			aged = s.Estimate(key) < 8
+		}
+		assert.True(t, aged)
+		assert.True(t, s.sketch.size < s.sketch.sampleSize/2)
+	})
+
+	t.Run("TestAgingFromObservedSize", func(t *testing.T) {
+		s := NewConcurrentCountMinSketch(16)
+		for i := range s.sketch.table {
+			s.sketch.table[i] = OneMask
+		}
+		// the odd counters correct the size by a quarter of their count, 64 in total
+		s.sketch.size = 1000
+		s.age()
+		assert.Equal(t, uint64(500-64), s.sketch.size)
+
+		for i := range s.sketch.table {
+			s.sketch.table[i] = OneMask
+		}
+		s.sketch.size = 10
+		s.age()
+		assert.Equal(t, uint64(0), s.sketch.size)
+	})
+
+	t.Run("TestMergeAndMarshal", func(t *testing.T) {
+		s1 := NewConcurrentCountMinSketch(512)
+		s2 := NewConcurrentCountMinSketch(512)
+		key := []byte("louyuting")
+		s1.Increment(key)
+		s2.Increment(key)
+		s2.Increment(key)
+		assert.Nil(t, s1.Merge(s2))
+		assert.Equal(t, 3, s1.Estimate(key))
+
+		data, err := s1.MarshalBinary()
+		assert.Nil(t, err)
+		restored := &ConcurrentCountMinSketch{}
+		assert.Nil(t, restored.UnmarshalBinary(data))
+		assert.Equal(t, 3, restored.Estimate(key))
+		s1.Reset()
+		assert.Equal(t, 0, s1.Estimate(key))
+	})
+}
@@ -0,0 +1,77 @@
+package cocoa
+
+import (
+	"math"
+)
+
+const (
+	// The false positive probability of the doorkeeper.
+	doorkeeperFpp = 0.01
+)
+
+// doorkeeper is a Bloom filter placed in front of the FrequencySketch, so that the keys seen only once
+// set a bit in the doorkeeper instead of occupying the 4-bit counters of the sketch.
+type doorkeeper struct {
+	bits []uint64
+	// the mask of the bit index, the number of bits is the power of 2
+	bitMask uint64
+	// the number of hash functions
+	hashes int
+}
+
+// newDoorkeeper returns the doorkeeper sized for the expected number of insertions between two clears.
+func newDoorkeeper(expectedInsertions int) *doorkeeper {
+	if expectedInsertions < 1 {
+		expectedInsertions = 1
+	}
+	// the optimal number of bits and hash functions of a Bloom filter
+	numBits := -float64(expectedInsertions) * math.Log(doorkeeperFpp) / (math.Ln2 * math.Ln2)
+	words := ceilingPowerOfTwo(int(math.Ceil(numBits / 64)))
+	hashes := int(math.Round(numBits / float64(expectedInsertions) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &doorkeeper{
+		bits:    make([]uint64, words),
+		bitMask: uint64(words*64 - 1),
+		hashes:  hashes,
+	}
+}
+
+// bitIndex returns the bit index of the i-th hash function by double hashing.
+func (d *doorkeeper) bitIndex(hash uint64, i int) uint64 {
+	h2 := (hash >> 32) | 1
+	return (hash + uint64(i)*h2) & d.bitMask
+}
+
+// put adds the hash to the doorkeeper, and returns if it was already present.
+func (d *doorkeeper) put(hash uint64) bool {
+	present := true
+	for i := 0; i < d.hashes; i++ {
+		idx := d.bitIndex(hash, i)
+		mask := uint64(1) << (idx & 63)
+		if d.bits[idx>>6]&mask == 0 {
+			present = false
+			d.bits[idx>>6] |= mask
+		}
+	}
+	return present
+}
+
+// contains returns if the hash might have been added.
+func (d *doorkeeper) contains(hash uint64) bool {
+	for i := 0; i < d.hashes; i++ {
+		idx := d.bitIndex(hash, i)
+		if d.bits[idx>>6]&(uint64(1)<<(idx&63)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// clear removes all of the hashes.
+func (d *doorkeeper) clear() {
+	for i := range d.bits {
+		d.bits[i] = 0
+	}
+}
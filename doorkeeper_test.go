@@ -0,0 +1,35 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestDoorkeeper(t *testing.T) {
+	t.Run("TestPutAndContains", func(t *testing.T) {
+		d := newDoorkeeper(1000)
+		h := uint64(hash([]byte("louyuting")))
+		assert.False(t, d.contains(h))
+		assert.False(t, d.put(h))
+		assert.True(t, d.contains(h))
+		assert.True(t, d.put(h))
+
+		d.clear()
+		assert.False(t, d.contains(h))
+	})
+
+	t.Run("TestFalsePositiveProbability", func(t *testing.T) {
+		d := newDoorkeeper(10000)
+		for i := 0; i < 10000; i++ {
+			d.put(uint64(hash([]byte(strconv.Itoa(i)))))
+		}
+		falsePositives := 0
+		for i := 10000; i < 20000; i++ {
+			if d.contains(uint64(hash([]byte(strconv.Itoa(i))))) {
+				falsePositives++
+			}
+		}
+		assert.True(t, falsePositives < 300, "false positives: %d", falsePositives)
+	})
+}
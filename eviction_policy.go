@@ -0,0 +1,111 @@
+package cocoa
+
+// EvictionPolicy is the page replacement policy of the cache, which decides the entries to evict when the
+// cache exceeds its maximum. The policy is driven by the maintenance under the eviction lock, the cache
+// keeps the total weight and the policy keeps its own structures.
+type EvictionPolicy interface {
+	// OnAdd adds the node to the policy.
+	OnAdd(n *Node)
+	// OnAccess records an access of the node.
+	OnAccess(n *Node)
+	// OnUpdate records that the weight of the node changed by the weightDiff.
+	OnUpdate(n *Node, weightDiff int)
+	// OnRemove removes the node from the policy, the node may be not added if it was deleted before.
+	OnRemove(n *Node)
+	// Evict evicts the nodes while the overweight returns true. A node is evicted by the evict, which calls
+	// OnRemove before it returns. The policy stops if the evict fails to remove a node, rather than retrying
+	// the same node.
+	Evict(overweight func() bool, evict func(n *Node))
+}
+
+// PolicyType selects the EvictionPolicy of a cache.
+type PolicyType int32
+
+const (
+	// WTinyLFUPolicy is the Window TinyLFU: an LRU window in front of a segmented LRU main space guarded by
+	// the AdmissionPolicy.
+	WTinyLFUPolicy PolicyType = iota
+	// LRUPolicy evicts the least recently used entry.
+	LRUPolicy
+	// LFUPolicy evicts the least frequently used entry, the least recently used one of the ties.
+	LFUPolicy
+	// FIFOPolicy evicts the oldest entry.
+	FIFOPolicy
+	// S3FIFOPolicy is the S3-FIFO: a small FIFO queue filtering the one-hit entries in front of a main FIFO
+	// queue, with a ghost queue of the recently evicted keys.
+	S3FIFOPolicy
+	// SampledLRUPolicy is the approximate LRU without deques, which evicts the least recently accessed of
+	// the randomly sampled entries. Its nodes are allocated without the links, which saves memory per entry.
+	SampledLRUPolicy
+	// GDSFPolicy is the Greedy-Dual-Size-Frequency, which evicts the entry of the lowest frequency per weight,
+	// aged by an inflation value. It prefers to keep the small hot entries of a weighted cache.
+	GDSFPolicy
+	// ARCPolicy is the Adaptive Replacement Cache, which balances the recency and the frequency lists by
+	// the hits of the ghost lists.
+	ARCPolicy
+	// LIRSPolicy is the Low Inter-reference Recency Set, which evicts the entries of a large reuse distance.
+	LIRSPolicy
+)
+
+// String returns the name of the policy.
+func (p PolicyType) String() string {
+	switch p {
+	case WTinyLFUPolicy:
+		return "W-TinyLFU"
+	case LRUPolicy:
+		return "LRU"
+	case LFUPolicy:
+		return "LFU"
+	case FIFOPolicy:
+		return "FIFO"
+	case S3FIFOPolicy:
+		return "S3-FIFO"
+	case SampledLRUPolicy:
+		return "Sampled-LRU"
+	case GDSFPolicy:
+		return "GDSF"
+	case ARCPolicy:
+		return "ARC"
+	case LIRSPolicy:
+		return "LIRS"
+	default:
+		return "Unknown"
+	}
+}
+
+// newEvictionPolicy returns the policy configured by the builder for the cache.
+func newEvictionPolicy(b *CacheBuilder, c *BoundedLocalCache) EvictionPolicy {
+	maximum := c.maximum
+	switch b.policy {
+	case LRUPolicy:
+		return newLRUPolicy()
+	case LFUPolicy:
+		return newLFUPolicy()
+	case FIFOPolicy:
+		return newFIFOPolicy()
+	case S3FIFOPolicy:
+		return newS3FIFOPolicy(maximum)
+	case SampledLRUPolicy:
+		return newSampledLRUPolicy(c.data, b.evictionSamples)
+	case GDSFPolicy:
+		return newGDSFPolicy(c.sketch)
+	case ARCPolicy:
+		return newARCPolicy(maximum)
+	case LIRSPolicy:
+		return newLIRSPolicy(maximum)
+	default:
+		return newWTinyLFUPolicy(maximum, c.admission)
+	}
+}
+
+// unlinkedNodes is implemented by the policies keeping no node in a deque, so the nodes are allocated without
+// the links.
+type unlinkedNodes interface {
+	unlinkedNodes()
+}
+
+// accessOrdered is implemented by the policies keeping the nodes in access order deques, so the least
+// recently accessed nodes, which expire first, are found at the heads.
+type accessOrdered interface {
+	accessOrderDeques() []*AccessOrderDeque
+}
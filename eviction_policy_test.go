@@ -0,0 +1,165 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// policyCacheForTest returns the cache of the policy, whose maintenance runs on the caller.
+func policyCacheForTest(policy PolicyType, maximum int) *BoundedLocalCache {
+	return NewCacheBuilder().MaximumSize(maximum).EvictionPolicy(policy).Executor(NewCallerRunsExecutor()).Build()
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	t.Run("LRU", func(t *testing.T) {
+		c := policyCacheForTest(LRUPolicy, 3)
+		a, b := putNodeForTest(c, "a"), putNodeForTest(c, "b")
+		putNodeForTest(c, "c")
+		c.onAccess(a)
+		putNodeForTest(c, "d")
+		c.evictEntries()
+		assert.True(t, b.isDead())
+		assert.True(t, a.isAlive())
+		assert.Equal(t, 3, c.weightedSize)
+	})
+
+	t.Run("FIFO", func(t *testing.T) {
+		c := policyCacheForTest(FIFOPolicy, 3)
+		a, b := putNodeForTest(c, "a"), putNodeForTest(c, "b")
+		putNodeForTest(c, "c")
+		c.onAccess(a)
+		putNodeForTest(c, "d")
+		c.evictEntries()
+		assert.True(t, a.isDead())
+		assert.True(t, b.isAlive())
+	})
+
+	t.Run("LFU", func(t *testing.T) {
+		c := policyCacheForTest(LFUPolicy, 3)
+		a, b, d := putNodeForTest(c, "a"), putNodeForTest(c, "b"), putNodeForTest(c, "d")
+		c.onAccess(a)
+		c.onAccess(a)
+		c.onAccess(b)
+		c.onAccess(d)
+		// the least recently used of the least frequent
+		e := putNodeForTest(c, "e")
+		c.evictEntries()
+		assert.True(t, e.isDead())
+
+		f := putNodeForTest(c, "f")
+		c.onAccess(f)
+		c.evictEntries()
+		assert.True(t, b.isDead())
+		assert.True(t, a.isAlive() && d.isAlive() && f.isAlive())
+
+		// the emptied minimum frequency is skipped
+		deleteNodeForTest(c, d)
+		(&DeleteTask{c: c, node: d}).run()
+		putNodeForTest(c, "g")
+		putNodeForTest(c, "h")
+		c.evictEntries()
+		assert.Equal(t, 3, c.weightedSize)
+		assert.Equal(t, 3, len(c.policy.(*lfuPolicy).frequencies))
+	})
+
+	t.Run("expiration by scan", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		c := NewCacheBuilder().MaximumSize(10).EvictionPolicy(LFUPolicy).Ticker(ticker).
+			ExpireAfterAccess(time.Minute).Executor(NewCallerRunsExecutor()).Build()
+		c.Put([]byte("a"), 1)
+		ticker.Advance(30 * time.Second)
+		c.Put([]byte("b"), 2)
+		c.performCleanUp(nil)
+		delay, found := c.nextExpirationDelay(ticker.Read())
+		assert.True(t, found)
+		assert.Equal(t, 30*time.Second, delay)
+
+		ticker.Advance(30 * time.Second)
+		c.performCleanUp(nil)
+		assert.False(t, c.Contains([]byte("a")))
+		assert.True(t, c.Contains([]byte("b")))
+	})
+
+	t.Run("expiration scan is bounded and resumes", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		e := &queuedExecutor{}
+		c := NewCacheBuilder().MaximumSize(20000).EvictionPolicy(LFUPolicy).Ticker(ticker).
+			ExpireAfterAccess(time.Minute).Executor(e).Build()
+		for i := 0; i < 10000; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		for e.runNext() {
+		}
+		ticker.Advance(time.Minute)
+
+		c.performCleanUp(nil)
+		assert.True(t, c.Size() > 0)
+		assert.True(t, c.Size() < 10000-maxScannedEntries/2)
+		assert.True(t, c.expirationScan.cursor > 0)
+		runs := 0
+		for e.runNext() {
+			runs++
+		}
+		assert.True(t, runs >= 1)
+		assert.Equal(t, 0, c.Size())
+		assert.Equal(t, 0, c.expirationScan.cursor)
+		// no entry may expire until the next pass
+		_, found := c.nextExpirationDelay(ticker.Read())
+		assert.False(t, found)
+	})
+
+	t.Run("policies bound the cache", func(t *testing.T) {
+		for _, policy := range []PolicyType{WTinyLFUPolicy, LRUPolicy, LFUPolicy, FIFOPolicy, S3FIFOPolicy, SampledLRUPolicy, GDSFPolicy, ARCPolicy, LIRSPolicy} {
+			c := policyCacheForTest(policy, 100)
+			for i := 0; i < 1000; i++ {
+				key := []byte{byte(i), byte(i >> 8)}
+				c.Put(key, i)
+				c.Get([]byte{byte(i / 2), byte(i / 2 >> 8)})
+			}
+			c.performCleanUp(nil)
+			assert.Equal(t, 100, c.Size(), policy.String())
+			assert.Equal(t, 100, c.weightedSize, policy.String())
+		}
+	})
+
+	t.Run("evictions stop if the evict fails", func(t *testing.T) {
+		for _, policy := range []PolicyType{WTinyLFUPolicy, LRUPolicy, LFUPolicy, FIFOPolicy, S3FIFOPolicy, GDSFPolicy, ARCPolicy,
+			LIRSPolicy} {
+			c := policyCacheForTest(policy, 100)
+			for i := 0; i < 10; i++ {
+				putNodeForTest(c, strconv.Itoa(i))
+			}
+			calls := 0
+			c.policy.Evict(func() bool {
+				calls++
+				return calls < 1000
+			}, func(n *Node) {})
+			assert.True(t, calls < 1000, policy.String())
+		}
+	})
+
+	t.Run("random operations keep the weights", func(t *testing.T) {
+		for _, policy := range []PolicyType{WTinyLFUPolicy, LRUPolicy, LFUPolicy, FIFOPolicy, S3FIFOPolicy,
+			SampledLRUPolicy, GDSFPolicy, ARCPolicy, LIRSPolicy} {
+			c := policyCacheForTest(policy, 50)
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 10000; i++ {
+				key := []byte(strconv.Itoa(r.Intn(200)))
+				switch r.Intn(4) {
+				case 0:
+					c.Delete(key)
+				case 1:
+					c.Put(key, i)
+				default:
+					c.Get(key)
+				}
+			}
+			c.performCleanUp(nil)
+			assert.Equal(t, c.Size(), c.weightedSize, policy.String())
+			assert.True(t, c.Size() <= 50, policy.String())
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package cocoa
+
+import (
+	"runtime"
+	"sync"
+)
+
+var (
+	defaultExecutorOnce sync.Once
+	defaultExecutor     *WorkerPoolExecutor
+)
+
+// DefaultExecutor returns the Executor shared by the caches built without one. It runs a worker goroutine
+// per CPU, started on the first use and kept for the life of the process.
+func DefaultExecutor() Executor {
+	defaultExecutorOnce.Do(func() {
+		defaultExecutor = NewWorkerPoolExecutor(runtime.NumCPU(), defaultExecutorQueueSize)
+	})
+	return defaultExecutor
+}
+
+// Executor executes the asynchronous work of the cache: the maintenance of the page replacement
+// policy and the removal notifications. An Executor may be shared by many caches.
+// Execute must not block the caller for long, since it is called on the read and write paths.
+type Executor interface {
+	Execute(fn func())
+}
+
+// callerRunsExecutor runs the function on the caller's goroutine.
+type callerRunsExecutor struct{}
+
+// NewCallerRunsExecutor returns an Executor that runs the function on the caller's goroutine,
+// which makes the cache fully deterministic, e.g. in tests.
+func NewCallerRunsExecutor() Executor {
+	return callerRunsExecutor{}
+}
+
+func (e callerRunsExecutor) Execute(fn func()) {
+	fn()
+}
+
+// WorkerPoolExecutor runs the functions on a fixed number of worker goroutines, which take the
+// functions from a bounded queue. When the queue is full or the executor is shut down, the
+// function is run on the caller's goroutine rather than blocking it.
+type WorkerPoolExecutor struct {
+	tasks    chan func()
+	mux      sync.RWMutex
+	shutdown bool
+}
+
+// NewWorkerPoolExecutor returns an Executor with the number of worker goroutines and the queue size.
+func NewWorkerPoolExecutor(workers int, queueSize int) *WorkerPoolExecutor {
+	if workers <= 0 {
+		panic("workers must be positive.")
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	e := &WorkerPoolExecutor{
+		tasks: make(chan func(), queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go e.work()
+	}
+	return e
+}
+
+// NewDedicatedExecutor returns an Executor that runs the functions one by one on a dedicated goroutine.
+func NewDedicatedExecutor(queueSize int) *WorkerPoolExecutor {
+	return NewWorkerPoolExecutor(1, queueSize)
+}
+
+func (e *WorkerPoolExecutor) work() {
+	for fn := range e.tasks {
+		fn()
+	}
+}
+
+func (e *WorkerPoolExecutor) Execute(fn func()) {
+	e.mux.RLock()
+	if e.shutdown {
+		e.mux.RUnlock()
+		fn()
+		return
+	}
+	select {
+	case e.tasks <- fn:
+		e.mux.RUnlock()
+	default:
+		e.mux.RUnlock()
+		fn()
+	}
+}
+
+// Shutdown stops the worker goroutines after the queued functions are run.
+// The functions executed after shutdown are run on the caller's goroutine.
+func (e *WorkerPoolExecutor) Shutdown() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.shutdown {
+		return
+	}
+	e.shutdown = true
+	close(e.tasks)
+}
@@ -0,0 +1,74 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestExecutor(t *testing.T) {
+	t.Run("TestCallerRunsExecutor", func(t *testing.T) {
+		ran := false
+		NewCallerRunsExecutor().Execute(func() {
+			ran = true
+		})
+		assert.True(t, ran)
+	})
+
+	t.Run("TestWorkerPoolExecutor", func(t *testing.T) {
+		e := NewWorkerPoolExecutor(4, 16)
+		defer e.Shutdown()
+		wg := sync.WaitGroup{}
+		mux := sync.Mutex{}
+		count := 0
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			e.Execute(func() {
+				defer wg.Done()
+				mux.Lock()
+				count++
+				mux.Unlock()
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, 100, count)
+	})
+
+	t.Run("TestWorkerPoolExecutorRunsInCallerWhenFull", func(t *testing.T) {
+		e := NewDedicatedExecutor(1)
+		defer e.Shutdown()
+		block := make(chan struct{})
+		started := make(chan struct{})
+		e.Execute(func() {
+			close(started)
+			<-block
+		})
+		<-started
+		// fill the queue while the worker is blocked
+		e.Execute(func() {})
+		ran := false
+		e.Execute(func() {
+			ran = true
+		})
+		assert.True(t, ran)
+		close(block)
+	})
+
+	t.Run("TestWorkerPoolExecutorShutdown", func(t *testing.T) {
+		e := NewDedicatedExecutor(4)
+		e.Shutdown()
+		e.Shutdown()
+		ran := false
+		e.Execute(func() {
+			ran = true
+		})
+		assert.True(t, ran)
+	})
+}
+
+func TestDefaultExecutor(t *testing.T) {
+	assert.True(t, DefaultExecutor() == DefaultExecutor())
+	c1 := NewBoundedLocalCache(10)
+	c2 := NewBoundedLocalCache(10)
+	assert.True(t, c1.executor == c2.executor)
+}
@@ -1,6 +1,18 @@
 package cocoa
 
-import "math"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// The default multiplier of the sample size to the table length.
+	defaultSampleMultiplier = 10
+)
 
 var (
 	Seed        = [...]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
@@ -9,52 +21,117 @@ var (
 	MaxCapacity = 1 << 30
 )
 
+// SketchLayout is how the four counters of a key are placed in the table of a FrequencySketch.
+type SketchLayout int32
+
+const (
+	// ScatteredLayout places the four counters in four random words of the table.
+	ScatteredLayout SketchLayout = iota
+	// BlockedLayout places the four counters in one 64-byte block of the table selected by the hash,
+	// so that an increment or a frequency costs a single cache miss.
+	BlockedLayout
+)
+
+const (
+	// The number of words of a 64-byte block.
+	blockWords = 8
+)
+
 type FrequencySketch struct {
 	table     []uint64
 	tableMask uint64
+	layout    SketchLayout
+	// the mask of the block index, only used by the blocked layout
+	blockMask uint64
 
 	sampleSize uint64
 	size       uint64
+	// the counters are halved once size reaches sampleMultiplier times of the table length
+	sampleMultiplier uint64
+
+	// the counters are halved every decayInterval nanoseconds of the ticker time if it is positive,
+	// instead of by the sample size
+	decayInterval int64
+	ticker        Ticker
+	nextDecay     int64
+
+	// the optional doorkeeper, nil if disabled
+	doorkeeper *doorkeeper
+	// the seed of the sketchHash of the keys, which is encoded with the table
+	seed uint64
 }
 
 func NewFrequencySketch(capacity int) *FrequencySketch {
-	f := &FrequencySketch{}
+	return newFrequencySketch(capacity, ScatteredLayout)
+}
+
+// NewBlockedFrequencySketch returns the sketch with the blocked layout.
+func NewBlockedFrequencySketch(capacity int) *FrequencySketch {
+	return newFrequencySketch(capacity, BlockedLayout)
+}
+
+func newFrequencySketch(capacity int, layout SketchLayout) *FrequencySketch {
+	f := &FrequencySketch{layout: layout, sampleMultiplier: defaultSampleMultiplier}
 	f.ensureCapacity(capacity)
 	return f
 }
 
+// NewFrequencySketchWithDoorkeeper returns the sketch with a doorkeeper, which filters out the keys seen only once
+// since the last reset.
+func NewFrequencySketchWithDoorkeeper(capacity int) *FrequencySketch {
+	f := NewFrequencySketch(capacity)
+	f.enableDoorkeeper()
+	return f
+}
+
+// enableDoorkeeper places a doorkeeper in front of the sketch.
+func (f *FrequencySketch) enableDoorkeeper() {
+	f.doorkeeper = newDoorkeeper(int(f.sampleSize))
+}
+
+// resizeDoorkeeper sizes the doorkeeper, if any, for the sample size, as it is only cleared by the reset.
+func (f *FrequencySketch) resizeDoorkeeper() {
+	if f.doorkeeper != nil {
+		f.enableDoorkeeper()
+	}
+}
+
 func (f *FrequencySketch) ensureCapacity(maxSize int) {
 	if maxSize < 0 {
 		maxSize = 0
 	}
 	maxSize = int(math.Min(float64(maxSize), float64(MaxCapacity)))
-	if len(f.table) > maxSize {
+	maxSize = ceilingPowerOfTwo(maxSize)
+	if f.layout == BlockedLayout && maxSize < blockWords {
+		maxSize = blockWords
+	}
+	// a grown or restored table is kept with its counters
+	if len(f.table) >= maxSize {
 		return
 	}
-
-	maxSize = ceilingPowerOfTwo(maxSize)
 	f.table = make([]uint64, maxSize)
 	f.tableMask = uint64(maxSize - 1)
+	f.blockMask = uint64(maxSize/blockWords) - 1
 	f.size = 0
-	f.sampleSize = 10 * uint64(maxSize)
-
+	f.sampleSize = f.sampleMultiplier * uint64(maxSize)
+	f.resizeDoorkeeper()
 }
 
 // frequency returns the estimated number of occurrences of an element, up to the maximum (15).
 func (f *FrequencySketch) frequency(key []byte) int {
-	// hash the key
-	hash := uint64(hash(key))
-	// counter index in table[idx]
-	// start in [0,4,8,12]
-	start := int((hash & 3) << 2)
+	hash := f.hash(key)
+	idx, ordinate := f.counters(hash)
 
 	frequency := 15
 	for i := 0; i < 4; i++ {
-		idx := f.indexOf(hash, i)
-		count := (f.table[idx] >> ((start + i) << 2)) & 0xf
+		count := (f.table[idx[i]] >> (ordinate[i] << 2)) & 0xf
 		frequency = int(math.Min(float64(frequency), float64(count)))
 	}
 
+	// the first occurrence is only recorded in the doorkeeper
+	if f.doorkeeper != nil && frequency < 15 && f.doorkeeper.contains(hash) {
+		frequency++
+	}
 	return frequency
 }
 
@@ -62,36 +139,121 @@ func (f *FrequencySketch) frequency(key []byte) int {
 // of all elements will be periodically down sampled when the observed events exceeds a threshold.
 // This process provides a frequency aging to allow expired long term entries to fade away.
 func (f *FrequencySketch) increment(key []byte) {
-	// hash the key
-	hash := uint64(hash(key))
-	// counter index in table[idx]
-	// start in [0,4,8,12]
-	start := int((hash & 3) << 2)
+	hash := f.hash(key)
 
-	idx1 := f.indexOf(hash, 0)
-	idx2 := f.indexOf(hash, 1)
-	idx3 := f.indexOf(hash, 2)
-	idx4 := f.indexOf(hash, 3)
+	// the first occurrence only sets the doorkeeper, the sketch is incremented on repeats
+	if f.doorkeeper != nil && !f.doorkeeper.put(hash) {
+		f.recordSample(true)
+		return
+	}
+
+	idx, ordinate := f.counters(hash)
+
+	added := f.increaseAt(idx[0], ordinate[0])
+	added = f.increaseAt(idx[1], ordinate[1]) || added
+	added = f.increaseAt(idx[2], ordinate[2]) || added
+	added = f.increaseAt(idx[3], ordinate[3]) || added
+	f.recordSample(added)
+}
 
-	added := f.increaseAt(idx1, start)
-	added = f.increaseAt(idx2, start+1) || added
-	added = f.increaseAt(idx3, start+2) || added
-	added = f.increaseAt(idx4, start+3) || added
-	f.size++
+// recordSample ages the counters once the decay interval has passed, or once the occurrences recorded by the
+// doorkeeper or the counters reach the sample size.
+func (f *FrequencySketch) recordSample(recorded bool) {
+	if f.decayInterval > 0 {
+		f.decayIfDue()
+	} else if recorded {
+		f.size++
+		if f.size >= f.sampleSize {
+			f.reset()
+		}
+	}
+}
+
+// setSampleMultiplier sets the sample size to the multiplier times of the table length.
+func (f *FrequencySketch) setSampleMultiplier(multiplier int) {
+	if multiplier <= 0 {
+		multiplier = defaultSampleMultiplier
+	}
+	f.sampleMultiplier = uint64(multiplier)
+	f.sampleSize = f.sampleMultiplier * uint64(len(f.table))
+	f.resizeDoorkeeper()
+}
+
+// setDecayInterval switches the aging to halve the counters every interval of the ticker time.
+func (f *FrequencySketch) setDecayInterval(interval time.Duration, ticker Ticker) {
+	f.decayInterval = int64(interval)
+	f.ticker = ticker
+	f.nextDecay = ticker.Read() + f.decayInterval
+}
 
-	if added && f.size == f.sampleSize {
-		f.reset()
+// decayIfDue halves the counters if the decay interval has passed since the last decay.
+func (f *FrequencySketch) decayIfDue() {
+	now := f.ticker.Read()
+	if now < f.nextDecay {
+		return
 	}
+	f.reset()
+	f.nextDecay = now + f.decayInterval
 }
 
-// Reduces every counter by half of its original value.
+// Reduces every counter by half of its original value, and clears the doorkeeper.
 func (f *FrequencySketch) reset() {
+	if f.doorkeeper != nil {
+		f.doorkeeper.clear()
+	}
 	count := 0
 	for i := 0; i < len(f.table); i++ {
 		count += bitCount(f.table[i] & OneMask)
 		f.table[i] = (f.table[i] >> 1) & ResetMask
 	}
-	f.size = (f.size >> 1) - uint64(count>>2)
+	// the odd counters were rounded down, but the doorkeeper occurrences are counted without any counter,
+	// so the correction is clamped
+	if correction := uint64(count >> 2); correction < f.size>>1 {
+		f.size = (f.size >> 1) - correction
+	} else {
+		f.size = 0
+	}
+}
+
+// hash returns the hash of the key, which does not depend on the process, so the saved counters are
+// restored to the same keys.
+func (f *FrequencySketch) hash(key []byte) uint64 {
+	return sketchHash(key, f.seed)
+}
+
+// counters returns the table index and the counter ordinate in [0-15] of the four counters of the hash.
+func (f *FrequencySketch) counters(hash uint64) (idx [4]int, ordinate [4]int) {
+	if f.layout == BlockedLayout {
+		return f.blockedCounters(hash)
+	}
+	// counter index in table[idx]
+	// start in [0,4,8,12]
+	start := int((hash & 3) << 2)
+	for i := 0; i < 4; i++ {
+		idx[i] = f.indexOf(hash, i)
+		ordinate[i] = start + i
+	}
+	return
+}
+
+// blockedCounters selects a block of 8 words by the hash, and the counters within the block by the rehashed
+// hash. Each depth i owns two words of the block, the counter is in one of them.
+func (f *FrequencySketch) blockedCounters(hash uint64) (idx [4]int, ordinate [4]int) {
+	block := int((hash & f.blockMask) * blockWords)
+	counterHash := rehash(hash)
+	for i := 0; i < 4; i++ {
+		h := counterHash >> (uint(i) << 3)
+		ordinate[i] = int((h >> 1) & 15)
+		idx[i] = block + int(h&1) + (i << 1)
+	}
+	return
+}
+
+// rehash mixes the hash, so that the counters are independent of the block selected by the low bits.
+func rehash(hash uint64) uint32 {
+	h := hash * Seed[0]
+	h ^= h >> 32
+	return uint32(h)
 }
 
 // Returns the table index for the counter at the specified depth.
@@ -117,3 +279,105 @@ func (f *FrequencySketch) increaseAt(idx, ordinate int) bool {
 	}
 	return false
 }
+
+const (
+	// The version of the binary encoding of the sketch, the version 1 was indexed by the hash seeded per process.
+	sketchEncodingVersion = 2
+	// version(1) + layout(1) + seed(8) + sampleSize(8) + size(8) + table length(4)
+	sketchHeaderSize = 30
+)
+
+// marshalBinary encodes the sketch's layout, seed, sampleSize, size and table. The doorkeeper is not encoded.
+func (f *FrequencySketch) marshalBinary() []byte {
+	data := make([]byte, sketchHeaderSize+8*len(f.table))
+	data[0] = sketchEncodingVersion
+	data[1] = byte(f.layout)
+	binary.BigEndian.PutUint64(data[2:], f.seed)
+	binary.BigEndian.PutUint64(data[10:], f.sampleSize)
+	binary.BigEndian.PutUint64(data[18:], f.size)
+	binary.BigEndian.PutUint32(data[26:], uint32(len(f.table)))
+	for i, word := range f.table {
+		binary.BigEndian.PutUint64(data[sketchHeaderSize+8*i:], word)
+	}
+	return data
+}
+
+// unmarshalBinary decodes the sketch encoded by marshalBinary, replacing the sketch's table.
+func (f *FrequencySketch) unmarshalBinary(data []byte) error {
+	if len(data) < sketchHeaderSize {
+		return errors.New("cocoa: sketch data is too short")
+	}
+	if data[0] != sketchEncodingVersion {
+		return fmt.Errorf("cocoa: unsupported sketch encoding version %d", data[0])
+	}
+	layout := SketchLayout(data[1])
+	if layout != ScatteredLayout && layout != BlockedLayout {
+		return fmt.Errorf("cocoa: unknown sketch layout %d", layout)
+	}
+	length := int(binary.BigEndian.Uint32(data[26:]))
+	if length <= 0 || length > MaxCapacity || length&(length-1) != 0 ||
+		(layout == BlockedLayout && length < blockWords) {
+		return fmt.Errorf("cocoa: illegal sketch table length %d", length)
+	}
+	if len(data) != sketchHeaderSize+8*length {
+		return fmt.Errorf("cocoa: sketch data length %d mismatches the table length %d", len(data), length)
+	}
+	// the sketch would age on every increment
+	if binary.BigEndian.Uint64(data[10:]) == 0 {
+		return errors.New("cocoa: sketch sample size is zero")
+	}
+	table := make([]uint64, length)
+	for i := range table {
+		table[i] = binary.BigEndian.Uint64(data[sketchHeaderSize+8*i:])
+	}
+	f.layout = layout
+	f.table = table
+	f.tableMask = uint64(length - 1)
+	f.blockMask = uint64(length/blockWords) - 1
+	f.seed = binary.BigEndian.Uint64(data[2:])
+	f.sampleSize = binary.BigEndian.Uint64(data[10:])
+	f.size = binary.BigEndian.Uint64(data[18:])
+	f.resizeDoorkeeper()
+	return nil
+}
+
+// readSketch reads the sketch encoded by marshalBinary from r, validating the version header and that the
+// table has the given length and layout. A length of 0 accepts any valid table length.
+func readSketch(r io.Reader, length int, layout SketchLayout) ([]byte, error) {
+	header := make([]byte, sketchHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("cocoa: failed to read the sketch header: %v", err)
+	}
+	if header[0] != sketchEncodingVersion {
+		return nil, fmt.Errorf("cocoa: unsupported sketch encoding version %d", header[0])
+	}
+	if SketchLayout(header[1]) != layout {
+		return nil, fmt.Errorf("cocoa: sketch layout %d mismatches the expected %d", header[1], layout)
+	}
+	n := int(binary.BigEndian.Uint32(header[26:]))
+	if length != 0 && n != length {
+		return nil, fmt.Errorf("cocoa: sketch table length %d mismatches the expected %d", n, length)
+	}
+	if n <= 0 || n > MaxCapacity || n&(n-1) != 0 {
+		return nil, fmt.Errorf("cocoa: illegal sketch table length %d", n)
+	}
+	data := make([]byte, sketchHeaderSize+8*n)
+	copy(data, header)
+	if _, err := io.ReadFull(r, data[sketchHeaderSize:]); err != nil {
+		return nil, fmt.Errorf("cocoa: failed to read the sketch table: %v", err)
+	}
+	return data, nil
+}
+
+// addCounters adds the 16 counters of two words, saturating each at the maximum (15).
+func addCounters(a, b uint64) uint64 {
+	sum := uint64(0)
+	for offset := uint(0); offset < 64; offset += 4 {
+		count := (a>>offset)&0xf + (b>>offset)&0xf
+		if count > 15 {
+			count = 15
+		}
+		sum |= count << offset
+	}
+	return sum
+}
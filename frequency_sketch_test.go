@@ -2,7 +2,10 @@ package cocoa
 
 import (
 	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestFrequencySketch_increment(t *testing.T) {
@@ -17,3 +20,266 @@ func TestFrequencySketch_increment(t *testing.T) {
 		fmt.Println(f.frequency(key))
 	})
 }
+
+func TestFrequencySketch_ensureCapacity(t *testing.T) {
+	t.Run("TestKeepsCountersOfSameSize", func(t *testing.T) {
+		f := NewFrequencySketch(64)
+		key := []byte{'l', 'o', 'u'}
+		f.increment(key)
+		f.increment(key)
+		f.ensureCapacity(64)
+		f.ensureCapacity(33)
+		assert.Equal(t, 64, len(f.table))
+		assert.Equal(t, 2, f.frequency(key))
+
+		f.ensureCapacity(65)
+		assert.Equal(t, 128, len(f.table))
+		assert.Equal(t, 0, f.frequency(key))
+	})
+}
+
+func TestFrequencySketch_doorkeeper(t *testing.T) {
+	t.Run("TestFirstOccurrenceOnlySetsDoorkeeper", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(512)
+		key := []byte{'l', 'o', 'u'}
+		f.increment(key)
+		assert.Equal(t, 1, f.frequency(key))
+		for _, word := range f.table {
+			assert.Equal(t, uint64(0), word)
+		}
+
+		f.increment(key)
+		assert.Equal(t, 2, f.frequency(key))
+		for i := 0; i < 20; i++ {
+			f.increment(key)
+		}
+		assert.Equal(t, 15, f.frequency(key))
+	})
+
+	t.Run("TestResetClearsDoorkeeper", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(512)
+		key := []byte{'l', 'o', 'u'}
+		f.increment(key)
+		f.increment(key)
+		f.increment(key)
+		assert.Equal(t, 3, f.frequency(key))
+		f.reset()
+		assert.Equal(t, 1, f.frequency(key))
+		assert.False(t, f.doorkeeper.contains(f.hash(key)))
+	})
+
+	t.Run("TestSampleSizeCountsDoorkeeperOccurrences", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(16)
+		for i := uint64(0); i < f.sampleSize-1; i++ {
+			f.increment([]byte(strconv.Itoa(int(i))))
+		}
+		assert.Equal(t, f.sampleSize-1, f.size)
+		f.increment([]byte("louyuting"))
+		assert.True(t, f.size <= f.sampleSize/2)
+	})
+
+	t.Run("TestResetPeriodsWithDoorkeeper", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(64)
+		resets := 0
+		previous := f.size
+		for i := 0; i < 20*int(f.sampleSize); i++ {
+			// a few hot keys whose counters are odd at the resets, among the keys seen once
+			if i%2 == 0 {
+				f.increment([]byte(strconv.Itoa(i % 40)))
+			} else {
+				f.increment([]byte(strconv.Itoa(i)))
+			}
+			assert.True(t, f.size < f.sampleSize, "size %d at %d", f.size, i)
+			if f.size < previous {
+				resets++
+			}
+			previous = f.size
+		}
+		assert.True(t, resets >= 10, "resets %d", resets)
+	})
+
+	t.Run("TestDoorkeeperSizedForSampleSize", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(1024)
+		// the doorkeeper is full just before the first reset
+		for i := uint64(0); i < f.sampleSize-1; i++ {
+			f.increment([]byte(strconv.Itoa(int(i))))
+		}
+		assert.Equal(t, f.sampleSize-1, f.size)
+		falsePositives := 0
+		for i := 0; i < 10000; i++ {
+			if f.doorkeeper.contains(f.hash([]byte("absent" + strconv.Itoa(i)))) {
+				falsePositives++
+			}
+		}
+		assert.True(t, falsePositives < 300, "false positives %d", falsePositives)
+	})
+
+	t.Run("TestDoorkeeperResizedWithSampleSize", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(64)
+		bits := len(f.doorkeeper.bits)
+		f.setSampleMultiplier(40)
+		assert.True(t, len(f.doorkeeper.bits) > bits)
+		bits = len(f.doorkeeper.bits)
+		f.ensureCapacity(1024)
+		assert.True(t, len(f.doorkeeper.bits) > bits)
+		assert.Equal(t, len(newDoorkeeper(int(f.sampleSize)).bits), len(f.doorkeeper.bits))
+	})
+
+	t.Run("TestResetClampsSize", func(t *testing.T) {
+		f := NewFrequencySketchWithDoorkeeper(64)
+		for i := range f.table {
+			f.table[i] = OneMask
+		}
+		f.size = 10
+		f.reset()
+		assert.Equal(t, uint64(0), f.size)
+	})
+}
+
+func TestFrequencySketch_blockedLayout(t *testing.T) {
+	t.Run("TestCountersInOneBlock", func(t *testing.T) {
+		f := NewBlockedFrequencySketch(512)
+		for i := 0; i < 1000; i++ {
+			idx, ordinate := f.counters(f.hash([]byte(strconv.Itoa(i))))
+			block := idx[0] / blockWords
+			for d := 0; d < 4; d++ {
+				assert.Equal(t, block, idx[d]/blockWords)
+				assert.True(t, ordinate[d] >= 0 && ordinate[d] < 16)
+			}
+		}
+	})
+
+	t.Run("TestMinimumTableSize", func(t *testing.T) {
+		f := NewBlockedFrequencySketch(1)
+		assert.Equal(t, blockWords, len(f.table))
+		key := []byte{'l', 'o', 'u'}
+		f.increment(key)
+		f.increment(key)
+		assert.Equal(t, 2, f.frequency(key))
+	})
+
+	t.Run("TestIncrementUpToMaximum", func(t *testing.T) {
+		f := NewBlockedFrequencySketch(512)
+		key := []byte{'l', 'o', 'u'}
+		for i := 0; i < 20; i++ {
+			f.increment(key)
+		}
+		assert.Equal(t, 15, f.frequency(key))
+	})
+
+	t.Run("TestAccuracyEquivalentToScattered", func(t *testing.T) {
+		// a skewed stream, key i occurs about n/(i+1) times
+		capacity := 1 << 12
+		keys := 4 * capacity
+		errorOf := func(f *FrequencySketch) float64 {
+			counts := make(map[int]int)
+			for i := 0; i < keys; i++ {
+				n := keys / (i + 1) / 64
+				if n == 0 {
+					n = 1
+				}
+				for j := 0; j < n && j < 15; j++ {
+					f.increment([]byte(strconv.Itoa(i)))
+				}
+				if n > 15 {
+					n = 15
+				}
+				counts[i] = n
+			}
+			total := 0
+			for i, n := range counts {
+				total += f.frequency([]byte(strconv.Itoa(i))) - n
+			}
+			return float64(total) / float64(len(counts))
+		}
+		scattered := errorOf(NewFrequencySketch(capacity))
+		blocked := errorOf(NewBlockedFrequencySketch(capacity))
+		assert.True(t, blocked <= 1.5*scattered+0.05, "blocked error %f, scattered error %f", blocked, scattered)
+	})
+}
+
+func TestFrequencySketch_aging(t *testing.T) {
+	t.Run("sample multiplier", func(t *testing.T) {
+		f := NewFrequencySketch(64)
+		assert.Equal(t, uint64(10*64), f.sampleSize)
+		f.setSampleMultiplier(2)
+		assert.Equal(t, uint64(2*64), f.sampleSize)
+
+		key := []byte("hot")
+		for i := 0; i < 15; i++ {
+			f.increment(key)
+		}
+		assert.Equal(t, 15, f.frequency(key))
+		for i := 0; f.size > 0 && i < 2*64; i++ {
+			f.increment([]byte(strconv.Itoa(i)))
+		}
+		assert.True(t, f.frequency(key) < 15)
+	})
+
+	t.Run("time decay", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		f := NewFrequencySketch(64)
+		f.setDecayInterval(time.Minute, ticker)
+
+		key := []byte("hot")
+		// far more increments than the sample size do not age the counters
+		for i := 0; i < 8; i++ {
+			f.increment(key)
+		}
+		for i := 0; i < 20*64; i++ {
+			f.increment([]byte("other"))
+		}
+		assert.Equal(t, 8, f.frequency(key))
+
+		ticker.Advance(59 * time.Second)
+		f.increment([]byte("other"))
+		assert.Equal(t, 8, f.frequency(key))
+
+		ticker.Advance(time.Second)
+		f.increment([]byte("other"))
+		assert.Equal(t, 4, f.frequency(key))
+
+		// the next decay is a whole interval after the last one
+		ticker.Advance(30 * time.Second)
+		f.increment([]byte("other"))
+		assert.Equal(t, 4, f.frequency(key))
+	})
+
+	t.Run("builder", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(100).SketchSampleMultiplier(4).Build()
+		assert.Equal(t, uint64(4*len(c.sketch.table)), c.sketch.sampleSize)
+
+		ticker := NewFakeTicker()
+		c = NewCacheBuilder().MaximumSize(100).Ticker(ticker).SketchDecayInterval(time.Second).Build()
+		assert.Equal(t, int64(time.Second), c.sketch.decayInterval)
+		assert.Equal(t, ticker.Read()+int64(time.Second), c.sketch.nextDecay)
+	})
+}
+
+func BenchmarkFrequencySketch(b *testing.B) {
+	// a table of 32MB, much larger than the cpu caches
+	capacity := 1 << 22
+	keys := make([][]byte, 1<<16)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i * 7919))
+	}
+	for _, layout := range []SketchLayout{ScatteredLayout, BlockedLayout} {
+		name := "Scattered"
+		if layout == BlockedLayout {
+			name = "Blocked"
+		}
+		f := newFrequencySketch(capacity, layout)
+		b.Run(name+"_increment", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f.increment(keys[i&(len(keys)-1)])
+			}
+		})
+		b.Run(name+"_frequency", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f.frequency(keys[i&(len(keys)-1)])
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package cocoa
+
+import (
+	"container/heap"
+)
+
+// gdsfEntry is a node in the priority queue of the GDSF policy.
+type gdsfEntry struct {
+	node     *Node
+	priority float64
+	index    int
+}
+
+// gdsfPolicy is the Greedy-Dual-Size-Frequency policy, which evicts the entry of the lowest priority:
+// the inflation plus the frequency estimated by the sketch divided by the weight. The inflation is raised
+// to the priority of each evicted entry, so the entries not accessed recently age out.
+type gdsfPolicy struct {
+	sketch    *FrequencySketch
+	inflation float64
+	entries   []*gdsfEntry
+	index     map[*Node]*gdsfEntry
+}
+
+func newGDSFPolicy(sketch *FrequencySketch) *gdsfPolicy {
+	return &gdsfPolicy{
+		sketch: sketch,
+		index:  make(map[*Node]*gdsfEntry),
+	}
+}
+
+func (p *gdsfPolicy) OnAdd(n *Node) {
+	e := &gdsfEntry{node: n, priority: p.priorityOf(n)}
+	p.index[n] = e
+	heap.Push(p, e)
+}
+
+func (p *gdsfPolicy) OnAccess(n *Node) {
+	p.reprioritize(n)
+}
+
+func (p *gdsfPolicy) OnUpdate(n *Node, weightDiff int) {
+	p.reprioritize(n)
+}
+
+func (p *gdsfPolicy) OnRemove(n *Node) {
+	e, ok := p.index[n]
+	if !ok {
+		return
+	}
+	delete(p.index, n)
+	heap.Remove(p, e.index)
+}
+
+func (p *gdsfPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() && len(p.entries) > 0 {
+		victim := p.entries[0]
+		// stop rather than spin if the evict failed to remove the node
+		if victim.node == previous {
+			return
+		}
+		previous = victim.node
+		p.inflation = victim.priority
+		evict(victim.node)
+	}
+}
+
+// priorityOf returns the priority of the node, a node weighing zero is prioritized as weighing one.
+func (p *gdsfPolicy) priorityOf(n *Node) float64 {
+	weight := n.weight
+	if weight < 1 {
+		weight = 1
+	}
+	return p.inflation + float64(p.sketch.frequency(n.Key))/float64(weight)
+}
+
+func (p *gdsfPolicy) reprioritize(n *Node) {
+	e, ok := p.index[n]
+	if !ok {
+		return
+	}
+	e.priority = p.priorityOf(n)
+	heap.Fix(p, e.index)
+}
+
+// heap.Interface
+
+func (p *gdsfPolicy) Len() int { return len(p.entries) }
+
+func (p *gdsfPolicy) Less(i, j int) bool { return p.entries[i].priority < p.entries[j].priority }
+
+func (p *gdsfPolicy) Swap(i, j int) {
+	p.entries[i], p.entries[j] = p.entries[j], p.entries[i]
+	p.entries[i].index = i
+	p.entries[j].index = j
+}
+
+func (p *gdsfPolicy) Push(x interface{}) {
+	e := x.(*gdsfEntry)
+	e.index = len(p.entries)
+	p.entries = append(p.entries, e)
+}
+
+func (p *gdsfPolicy) Pop() interface{} {
+	n := len(p.entries)
+	e := p.entries[n-1]
+	p.entries = p.entries[:n-1]
+	return e
+}
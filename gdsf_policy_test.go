@@ -0,0 +1,58 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestGDSFPolicy(t *testing.T) {
+	t.Run("evicts the large entry before the small hot ones", func(t *testing.T) {
+		weigher := func(key []byte, value interface{}) int {
+			return len(value.(string))
+		}
+		c := NewCacheBuilder().MaximumWeight(200).Weigher(weigher).EvictionPolicy(GDSFPolicy).
+			Executor(NewCallerRunsExecutor()).Build()
+		blob := []byte("blob")
+		c.Put(blob, string(make([]byte, 100)))
+		c.Get(blob)
+		for i := 0; i < 90; i++ {
+			key := []byte(strconv.Itoa(i))
+			c.Put(key, "v")
+			c.Get(key)
+		}
+		c.performCleanUp(nil)
+		assert.True(t, c.Contains(blob))
+
+		for i := 90; i < 110; i++ {
+			c.Put([]byte(strconv.Itoa(i)), "v")
+			c.performCleanUp(nil)
+		}
+		assert.False(t, c.Contains(blob))
+		assert.Equal(t, 110, c.Size())
+		assert.True(t, c.policy.(*gdsfPolicy).inflation > 0)
+	})
+
+	t.Run("inflation ages the entries", func(t *testing.T) {
+		c := policyCacheForTest(GDSFPolicy, 2)
+		p := c.policy.(*gdsfPolicy)
+		old := putNodeForTest(c, "old")
+		for i := 0; i < 3; i++ {
+			c.onAccess(old)
+		}
+		putNodeForTest(c, "a")
+		putNodeForTest(c, "b")
+		c.evictEntries()
+		inflation := p.inflation
+		assert.True(t, inflation > 0)
+		assert.True(t, old.isAlive())
+
+		// the new entries are prioritized above the inflation
+		n := putNodeForTest(c, "c")
+		assert.True(t, p.index[n].priority > inflation)
+		assert.Equal(t, 3, len(p.entries))
+		c.evictEntries()
+		assert.Equal(t, 2, len(p.entries))
+		assert.Equal(t, 2, len(p.index))
+	})
+}
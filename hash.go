@@ -27,6 +27,10 @@ func init() {
 	hashkey[3] |= 1
 }
 
+// sketchHashkey seeds the hash of the frequency sketches. Unlike the hashkey it is fixed, so a sketch saved
+// by one process counts the same keys when it is loaded by another.
+var sketchHashkey = [4]uintptr{0x9e3779b9, 0x85ebca6b, 0xc2b2ae35, 0x27d4eb2f}
+
 func add(p unsafe.Pointer, x uintptr) unsafe.Pointer {
 	return unsafe.Pointer(uintptr(p) + x)
 }
@@ -55,10 +59,16 @@ func readUnaligned32(p unsafe.Pointer) uint32 {
 
 func hash(src []byte) uintptr {
 	s := (*SliceHeader)(unsafe.Pointer(&src))
-	return memhash(s.Data, 0, uintptr(s.Len))
+	return memhash(s.Data, 0, uintptr(s.Len), &hashkey)
+}
+
+// sketchHash returns the hash of the key for the frequency sketches, which is the same in every process.
+func sketchHash(src []byte, seed uint64) uint64 {
+	s := (*SliceHeader)(unsafe.Pointer(&src))
+	return uint64(memhash(s.Data, uintptr(seed), uintptr(s.Len), &sketchHashkey))
 }
 
-func memhash(p unsafe.Pointer, seed, s uintptr) uintptr {
+func memhash(p unsafe.Pointer, seed, s uintptr, hashkey *[4]uintptr) uintptr {
 	h := uint64(seed + s*hashkey[0])
 tail:
 	switch {
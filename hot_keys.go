@@ -0,0 +1,123 @@
+package cocoa
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// HotKey is a hot key of the cache with its estimated frequency.
+type HotKey struct {
+	Key []byte
+	// the estimated frequency by the FrequencySketch, up to the maximum (15)
+	Frequency int
+}
+
+// hotKeyEntry is a key tracked by the hotKeys.
+type hotKeyEntry struct {
+	key       string
+	frequency int
+	// the accesses seen since the key is tracked, which breaks the ties of the saturated frequencies
+	hits  uint64
+	index int
+}
+
+// hotKeys tracks the top-K keys by the estimated frequency in a min-heap, fed from the access events drained
+// into onAccess. The least frequent tracked key is replaced by a more frequent one.
+// Caller must hold the eviction lock.
+type hotKeys struct {
+	capacity int
+	entries  []*hotKeyEntry
+	index    map[string]*hotKeyEntry
+}
+
+func newHotKeys(capacity int) *hotKeys {
+	return &hotKeys{
+		capacity: capacity,
+		entries:  make([]*hotKeyEntry, 0, capacity),
+		index:    make(map[string]*hotKeyEntry, capacity),
+	}
+}
+
+// record records an access of the key, whose estimated frequency is given. The frequency of the least
+// frequent key is re-estimated by the sketch before comparing, as the sketch may have aged since.
+func (h *hotKeys) record(key []byte, frequency int, sketch *FrequencySketch) {
+	if e, ok := h.index[*bytesToString(key)]; ok {
+		e.frequency = frequency
+		e.hits++
+		heap.Fix(h, e.index)
+		return
+	}
+	if len(h.entries) < h.capacity {
+		e := &hotKeyEntry{key: string(key), frequency: frequency, hits: 1}
+		h.index[e.key] = e
+		heap.Push(h, e)
+		return
+	}
+	min := h.entries[0]
+	min.frequency = sketch.frequency([]byte(min.key))
+	heap.Fix(h, 0)
+	min = h.entries[0]
+	if frequency <= min.frequency {
+		return
+	}
+	delete(h.index, min.key)
+	min.key = string(key)
+	min.frequency = frequency
+	min.hits = 1
+	h.index[min.key] = min
+	heap.Fix(h, 0)
+}
+
+// top returns at most k tracked keys, the most frequent first. The frequencies of the tracked keys are
+// re-estimated by the sketch, so a key which went cold since its last access is not reported as hot.
+func (h *hotKeys) top(k int, sketch *FrequencySketch) []HotKey {
+	for _, e := range h.entries {
+		e.frequency = sketch.frequency([]byte(e.key))
+	}
+	heap.Init(h)
+	sorted := make([]*hotKeyEntry, len(h.entries))
+	copy(sorted, h.entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return h.less(sorted[j], sorted[i])
+	})
+	if k > len(sorted) {
+		k = len(sorted)
+	}
+	result := make([]HotKey, k)
+	for i := 0; i < k; i++ {
+		result[i] = HotKey{Key: []byte(sorted[i].key), Frequency: sorted[i].frequency}
+	}
+	return result
+}
+
+func (h *hotKeys) less(a, b *hotKeyEntry) bool {
+	if a.frequency != b.frequency {
+		return a.frequency < b.frequency
+	}
+	return a.hits < b.hits
+}
+
+// heap.Interface
+
+func (h *hotKeys) Len() int { return len(h.entries) }
+
+func (h *hotKeys) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+
+func (h *hotKeys) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *hotKeys) Push(x interface{}) {
+	e := x.(*hotKeyEntry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *hotKeys) Pop() interface{} {
+	n := len(h.entries)
+	e := h.entries[n-1]
+	h.entries = h.entries[:n-1]
+	return e
+}
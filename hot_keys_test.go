@@ -0,0 +1,73 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestHotKeys(t *testing.T) {
+	t.Run("top", func(t *testing.T) {
+		sketch := NewFrequencySketch(1024)
+		h := newHotKeys(3)
+		access := func(key string, times int) {
+			for i := 0; i < times; i++ {
+				sketch.increment([]byte(key))
+				h.record([]byte(key), sketch.frequency([]byte(key)), sketch)
+			}
+		}
+		access("a", 2)
+		access("b", 6)
+		access("c", 4)
+		// not more frequent than the least frequent tracked key
+		access("d", 1)
+		assert.Equal(t, []HotKey{{[]byte("b"), 6}, {[]byte("c"), 4}, {[]byte("a"), 2}}, h.top(5, sketch))
+
+		access("e", 3)
+		assert.Equal(t, []HotKey{{[]byte("b"), 6}, {[]byte("c"), 4}}, h.top(2, sketch))
+		assert.Equal(t, []byte("e"), h.top(3, sketch)[2].Key)
+		assert.Equal(t, 3, len(h.index))
+	})
+
+	t.Run("hot key goes cold", func(t *testing.T) {
+		sketch := NewFrequencySketch(64)
+		h := newHotKeys(2)
+		access := func(key string, times int) {
+			for i := 0; i < times; i++ {
+				sketch.increment([]byte(key))
+				h.record([]byte(key), sketch.frequency([]byte(key)), sketch)
+			}
+		}
+		access("a", 8)
+		access("b", 4)
+		assert.Equal(t, []byte("a"), h.top(1, sketch)[0].Key)
+
+		// "a" is not accessed while the sketch ages, "b" is accessed again after
+		for sketch.frequency([]byte("a")) > 1 {
+			sketch.reset()
+		}
+		access("b", 4)
+		hot := h.top(2, sketch)
+		assert.Equal(t, []HotKey{{[]byte("b"), sketch.frequency([]byte("b"))}, {[]byte("a"), 1}}, hot)
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).TrackHotKeys(2).Build()
+		for i := 0; i < 10; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		for i := 0; i < 5; i++ {
+			c.Get([]byte("7"))
+			c.Get([]byte("3"))
+			c.Get([]byte("3"))
+		}
+		c.performCleanUp(nil)
+		hot := c.HotKeys(10)
+		assert.Equal(t, 2, len(hot))
+		assert.Equal(t, []byte("3"), hot[0].Key)
+		assert.Equal(t, []byte("7"), hot[1].Key)
+		assert.True(t, hot[0].Frequency >= hot[1].Frequency)
+
+		assert.Nil(t, NewBoundedLocalCache(100).HotKeys(10))
+	})
+}
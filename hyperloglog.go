@@ -0,0 +1,178 @@
+package cocoa
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// The precision of the HyperLogLog, which has 2^precision registers and a standard error of about
+	// 1.04/sqrt(2^precision), 1.6%.
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+	// The registers are bytes packed into the words, so they are updated by CAS.
+	hllRegistersPerWord = 4
+
+	// The default window of EstimatedDistinctKeys.
+	defaultDistinctKeysWindow = time.Minute
+	// The number of the slots a window is divided into, the oldest slot is cleared as the window rotates.
+	distinctKeysSlots = 4
+)
+
+// hyperLogLog estimates the number of the distinct hashes, it is safe for concurrent use.
+type hyperLogLog struct {
+	words [hllRegisters / hllRegistersPerWord]uint32
+}
+
+// add adds the hash, which only writes a register if its rank increases.
+func (l *hyperLogLog) add(h uint64) {
+	index := h >> (64 - hllPrecision)
+	rank := uint32(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1))) + 1
+	word := &l.words[index/hllRegistersPerWord]
+	shift := 8 * uint(index%hllRegistersPerWord)
+	for {
+		old := atomic.LoadUint32(word)
+		if (old>>shift)&0xff >= rank {
+			return
+		}
+		if atomic.CompareAndSwapUint32(word, old, old&^(0xff<<shift)|rank<<shift) {
+			return
+		}
+	}
+}
+
+func (l *hyperLogLog) clear() {
+	for i := range l.words {
+		atomic.StoreUint32(&l.words[i], 0)
+	}
+}
+
+// mergeInto merges the registers into the registers by the maximum.
+func (l *hyperLogLog) mergeInto(registers []uint8) {
+	for i := range l.words {
+		word := atomic.LoadUint32(&l.words[i])
+		for j := 0; j < hllRegistersPerWord; j++ {
+			if r := uint8(word >> (8 * uint(j))); r > registers[i*hllRegistersPerWord+j] {
+				registers[i*hllRegistersPerWord+j] = r
+			}
+		}
+	}
+}
+
+// estimateCardinality returns the estimated number of the distinct hashes of the registers by the improved
+// estimator of Ertl, "New cardinality estimation algorithms for HyperLogLog sketches", which corrects the
+// zero and the saturated registers, so it has no bias around the range the linear counting gives up.
+func estimateCardinality(registers []uint8) int64 {
+	const q = 64 - hllPrecision
+	var counts [q + 2]int
+	for _, r := range registers {
+		counts[r]++
+	}
+	m := float64(len(registers))
+	z := m * hllTau(1-float64(counts[q+1])/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + float64(counts[k]))
+	}
+	z += m * hllSigma(float64(counts[0])/m)
+	return int64(m*m/(2*math.Ln2*z) + 0.5)
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		previous := z
+		z += x * y
+		y += y
+		if z == previous {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		previous := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == previous {
+			return z / 3
+		}
+	}
+}
+
+// distinctKeys counts the distinct keys of a rotating window by a HyperLogLog per slot of the window, so
+// the estimate covers the previous slots and the elapsed part of the current slot.
+type distinctKeys struct {
+	// the current slot since the start of the ticker, advanced by CAS on rotation, first for the alignment
+	epoch int64
+
+	ticker Ticker
+	// the duration of a slot in nanoseconds
+	slotDuration int64
+	slots        [distinctKeysSlots]hyperLogLog
+}
+
+func newDistinctKeys(window time.Duration, ticker Ticker) *distinctKeys {
+	if window <= 0 {
+		window = defaultDistinctKeysWindow
+	}
+	slotDuration := int64(window) / distinctKeysSlots
+	if slotDuration == 0 {
+		slotDuration = 1
+	}
+	d := &distinctKeys{ticker: ticker, slotDuration: slotDuration}
+	d.epoch = ticker.Read() / slotDuration
+	return d
+}
+
+// record records the key of the hash at the time.
+func (d *distinctKeys) record(keyHash int, now int64) {
+	epoch := d.rotate(now)
+	d.slots[epoch%distinctKeysSlots].add(spreadDistinctHash(keyHash))
+}
+
+// spreadDistinctHash mixes all the bits of the hash of the key, whose low bits select the segment, into both
+// the register index and the rank.
+func spreadDistinctHash(h int) uint64 {
+	x := uint64(h)
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// rotate clears the slots expired since the last rotation, and returns the current slot. A key recorded
+// concurrently into a slot being cleared may be counted in the new slot, which is within the error.
+func (d *distinctKeys) rotate(now int64) int64 {
+	epoch := now / d.slotDuration
+	current := atomic.LoadInt64(&d.epoch)
+	if epoch > current && atomic.CompareAndSwapInt64(&d.epoch, current, epoch) {
+		for e := current + 1; e <= epoch && e <= current+distinctKeysSlots; e++ {
+			d.slots[e%distinctKeysSlots].clear()
+		}
+	}
+	return epoch
+}
+
+// estimate returns the estimated number of the distinct keys of the window.
+func (d *distinctKeys) estimate() int64 {
+	d.rotate(d.ticker.Read())
+	registers := make([]uint8, hllRegisters)
+	for i := range d.slots {
+		d.slots[i].mergeInto(registers)
+	}
+	return estimateCardinality(registers)
+}
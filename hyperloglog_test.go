@@ -0,0 +1,75 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	// the hash is seeded randomly, so the deltas allow 5 times of the standard error of 1.6%
+	data := newSegmentHashMap()
+	for _, n := range []int{0, 10, 1000, 10000, 100000, 1000000} {
+		var l hyperLogLog
+		for i := 0; i < n; i++ {
+			key := []byte("key-" + strconv.Itoa(i))
+			h := spreadDistinctHash(data.hash(key))
+			// the duplicates are not counted
+			l.add(h)
+			l.add(h)
+		}
+		registers := make([]uint8, hllRegisters)
+		l.mergeInto(registers)
+		assert.InDelta(t, n, estimateCardinality(registers), 0.08*float64(n)+1, strconv.Itoa(n))
+	}
+}
+
+func TestBoundedLocalCache_EstimatedDistinctKeys(t *testing.T) {
+	t.Run("gets and puts", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		for i := 0; i < 10000; i++ {
+			key := []byte(strconv.Itoa(i % 5000))
+			if c.Get(key) == nil {
+				c.Put(key, i)
+			}
+		}
+		assert.InDelta(t, 5000, c.EstimatedDistinctKeys(), 400)
+	})
+
+	t.Run("rotating window", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		c := NewCacheBuilder().MaximumSize(100).Ticker(ticker).DistinctKeysWindow(4 * time.Second).Build()
+		for i := 0; i < 1000; i++ {
+			c.Get([]byte("a" + strconv.Itoa(i)))
+		}
+		ticker.Advance(2 * time.Second)
+		for i := 0; i < 1000; i++ {
+			c.Get([]byte("b" + strconv.Itoa(i)))
+		}
+		assert.InDelta(t, 2000, c.EstimatedDistinctKeys(), 160)
+
+		// the first keys leave the window
+		ticker.Advance(3 * time.Second)
+		assert.InDelta(t, 1000, c.EstimatedDistinctKeys(), 80)
+		ticker.Advance(time.Hour)
+		assert.Equal(t, int64(0), c.EstimatedDistinctKeys())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 10000; i++ {
+					c.Get([]byte(strconv.Itoa(g*10000 + i)))
+				}
+			}(g)
+		}
+		wg.Wait()
+		assert.InDelta(t, 80000, c.EstimatedDistinctKeys(), 6400)
+	})
+}
@@ -0,0 +1,84 @@
+package cocoa
+
+// lfuPolicy evicts the least frequently used entry, the least recently used one of the ties. The nodes are
+// kept in a deque per frequency, so every operation is O(1) except that the minimum frequency is searched
+// upwards after it is emptied by a removal.
+type lfuPolicy struct {
+	// the frequency of each node in the policy
+	frequencies map[*Node]int
+	// the nodes of each frequency in access order
+	buckets map[int]*AccessOrderDeque
+	// the minimum frequency, which may be stale after a removal
+	minFrequency int
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{
+		frequencies: make(map[*Node]int),
+		buckets:     make(map[int]*AccessOrderDeque),
+	}
+}
+
+func (p *lfuPolicy) OnAdd(n *Node) {
+	p.frequencies[n] = 1
+	p.bucket(1).PushBack(n)
+	p.minFrequency = 1
+}
+
+func (p *lfuPolicy) OnAccess(n *Node) {
+	frequency, ok := p.frequencies[n]
+	if !ok {
+		return
+	}
+	p.removeFromBucket(n, frequency)
+	if frequency == p.minFrequency && p.buckets[frequency] == nil {
+		p.minFrequency++
+	}
+	p.frequencies[n] = frequency + 1
+	p.bucket(frequency + 1).PushBack(n)
+}
+
+func (p *lfuPolicy) OnUpdate(n *Node, weightDiff int) {}
+
+func (p *lfuPolicy) OnRemove(n *Node) {
+	frequency, ok := p.frequencies[n]
+	if !ok {
+		return
+	}
+	delete(p.frequencies, n)
+	p.removeFromBucket(n, frequency)
+}
+
+func (p *lfuPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() && len(p.frequencies) > 0 {
+		for p.buckets[p.minFrequency] == nil {
+			p.minFrequency++
+		}
+		node := p.buckets[p.minFrequency].GetFront()
+		// stop rather than spin if the evict failed to remove the node
+		if node == previous {
+			return
+		}
+		previous = node
+		evict(node)
+	}
+}
+
+func (p *lfuPolicy) bucket(frequency int) *AccessOrderDeque {
+	deque, ok := p.buckets[frequency]
+	if !ok {
+		deque = &AccessOrderDeque{}
+		p.buckets[frequency] = deque
+	}
+	return deque
+}
+
+// removeFromBucket removes the node from the deque of the frequency, and drops the deque if it is emptied.
+func (p *lfuPolicy) removeFromBucket(n *Node, frequency int) {
+	deque := p.buckets[frequency]
+	deque.Remove(n)
+	if deque.IsEmpty() {
+		delete(p.buckets, frequency)
+	}
+}
@@ -0,0 +1,208 @@
+package cocoa
+
+const (
+	// The LIR entries are linked in the stack S by their nodes, which reuse the Protected type of the node.
+	lirsLIR = Protected
+	// The resident HIR entries are linked in the queue Q by their nodes, which reuse the Probation type.
+	lirsHIR = Probation
+	// The HIR entries in S are linked by ghost nodes, which keep the Window type of a new node.
+	lirsGhost = Window
+)
+
+// lirsPolicy is the Low Inter-reference Recency Set policy. The LIR entries, whose reuse distance is small,
+// take most of the cache and are never evicted. The resident HIR entries are kept in the queue Q and are
+// evicted in FIFO order. The stack S holds the recency of the LIR entries and of the recent HIR entries,
+// either resident or non-resident, so that a HIR entry accessed again while in S is promoted to LIR and
+// the LIR entry at the bottom of S is demoted to HIR.
+// A resident HIR entry may be in both S and Q, while a node is linked in one deque, so a HIR entry is
+// linked in S by a ghost node, as the keys in the ghost lists of ARC. The LIR entries and the entries in Q
+// are linked by their own nodes.
+type lirsPolicy struct {
+	stack *AccessOrderDeque
+	queue *AccessOrderDeque
+	// the ghost nodes of the HIR entries in S by the key, whose value is the resident node, or nil if the
+	// entry is non-resident
+	ghosts map[string]*Node
+
+	lirWeight  int
+	lirMaximum int
+	// the number of non-resident entries in S, which is bounded by the maximum
+	nonResident    int
+	nonResidentMax int
+}
+
+func newLIRSPolicy(maximum int) *lirsPolicy {
+	hirMaximum := maximum / 100
+	if hirMaximum < 1 {
+		hirMaximum = 1
+	}
+	return &lirsPolicy{
+		stack:          &AccessOrderDeque{},
+		queue:          &AccessOrderDeque{},
+		ghosts:         make(map[string]*Node),
+		lirMaximum:     maximum - hirMaximum,
+		nonResidentMax: maximum,
+	}
+}
+
+func (p *lirsPolicy) OnAdd(n *Node) {
+	if ghost, ok := p.ghosts[*bytesToString(n.Key)]; ok {
+		// a HIR entry in S has a small reuse distance, the prior node of a replaced entry stays in Q
+		// until it is removed
+		p.removeGhost(ghost)
+		p.promote(n)
+		return
+	}
+	if p.lirWeight+n.weight <= p.lirMaximum {
+		// the LIR set is not full yet
+		n.makeIn(lirsLIR)
+		p.stack.PushBack(n)
+		p.lirWeight += n.weight
+		return
+	}
+	p.pushGhost(n)
+	n.makeIn(lirsHIR)
+	p.queue.PushBack(n)
+}
+
+func (p *lirsPolicy) OnAccess(n *Node) {
+	if p.isLIR(n) {
+		p.stack.MoveToBack(n)
+		p.prune()
+		return
+	}
+	if !p.isHIR(n) {
+		return
+	}
+	if ghost, ok := p.ghosts[*bytesToString(n.Key)]; ok && ghost.Value == n {
+		p.removeGhost(ghost)
+		p.queue.Remove(n)
+		p.promote(n)
+		return
+	}
+	p.pushGhost(n)
+	p.queue.MoveToBack(n)
+}
+
+func (p *lirsPolicy) OnUpdate(n *Node, weightDiff int) {
+	if p.isLIR(n) {
+		p.lirWeight += weightDiff
+	}
+}
+
+// OnRemove removes the resident entry, a HIR entry stays in S as a non-resident entry.
+func (p *lirsPolicy) OnRemove(n *Node) {
+	if p.isLIR(n) {
+		p.stack.Remove(n)
+		p.lirWeight -= n.weight
+		p.prune()
+		return
+	}
+	if !p.isHIR(n) {
+		return
+	}
+	p.queue.Remove(n)
+	if ghost, ok := p.ghosts[*bytesToString(n.Key)]; ok && ghost.Value == n {
+		ghost.Value = nil
+		p.nonResident++
+		p.boundNonResident()
+	}
+}
+
+// Evict evicts the resident HIR entries in FIFO order, and demotes the LIR entry at the bottom of S if
+// there are none.
+func (p *lirsPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() {
+		if front := p.queue.GetFront(); front != nil {
+			// stop rather than spin if the evict failed to remove the node
+			if front == previous {
+				return
+			}
+			previous = front
+			evict(front)
+			continue
+		}
+		if !p.demoteBottom() {
+			return
+		}
+	}
+}
+
+func (p *lirsPolicy) isLIR(n *Node) bool {
+	return n.queueType() == lirsLIR && p.stack.Contains(n)
+}
+
+func (p *lirsPolicy) isHIR(n *Node) bool {
+	return n.queueType() == lirsHIR && p.queue.Contains(n)
+}
+
+// promote makes the entry of the unlinked node LIR, and demotes the LIR entries at the bottom of S while
+// the LIR set is full.
+func (p *lirsPolicy) promote(n *Node) {
+	n.makeIn(lirsLIR)
+	p.stack.PushBack(n)
+	p.lirWeight += n.weight
+	for p.lirWeight > p.lirMaximum && p.demoteBottom() {
+	}
+	p.prune()
+}
+
+// demoteBottom demotes the LIR entry at the bottom of S to a resident HIR entry at the end of Q.
+// return if an entry was demoted
+func (p *lirsPolicy) demoteBottom() bool {
+	p.prune()
+	bottom := p.stack.GetFront()
+	if bottom == nil {
+		return false
+	}
+	p.stack.Remove(bottom)
+	p.lirWeight -= bottom.weight
+	bottom.makeIn(lirsHIR)
+	p.queue.PushBack(bottom)
+	p.prune()
+	return true
+}
+
+// prune removes the HIR entries at the bottom of S, so that the bottom is a LIR entry. The pruned
+// non-resident entries are removed from the policy.
+func (p *lirsPolicy) prune() {
+	for bottom := p.stack.GetFront(); bottom != nil && bottom.queueType() == lirsGhost; bottom = p.stack.GetFront() {
+		p.removeGhost(bottom)
+	}
+}
+
+// boundNonResident removes the non-resident entries nearest to the bottom of S while they exceed the bound.
+func (p *lirsPolicy) boundNonResident() {
+	for node := p.stack.GetFront(); node != nil && p.nonResident > p.nonResidentMax; {
+		next := node.nextNode()
+		if node.queueType() == lirsGhost && node.Value == nil {
+			p.removeGhost(node)
+		}
+		node = next
+	}
+}
+
+// pushGhost moves the ghost node of the resident HIR entry to the top of S.
+func (p *lirsPolicy) pushGhost(n *Node) {
+	ghost, ok := p.ghosts[*bytesToString(n.Key)]
+	if !ok {
+		ghost = &Node{Key: n.Key, Value: n}
+		p.ghosts[string(n.Key)] = ghost
+		p.stack.PushBack(ghost)
+		return
+	}
+	if ghost.Value == nil {
+		p.nonResident--
+	}
+	ghost.Value = n
+	p.stack.MoveToBack(ghost)
+}
+
+func (p *lirsPolicy) removeGhost(ghost *Node) {
+	p.stack.Remove(ghost)
+	delete(p.ghosts, string(ghost.Key))
+	if ghost.Value == nil {
+		p.nonResident--
+	}
+}
@@ -0,0 +1,110 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestLIRSPolicy(t *testing.T) {
+	t.Run("LIR entries survive a scan", func(t *testing.T) {
+		c := policyCacheForTest(LIRSPolicy, 100)
+		var lir []*Node
+		for i := 0; i < 99; i++ {
+			lir = append(lir, putNodeForTest(c, "lir"+strconv.Itoa(i)))
+		}
+		for i := 0; i < 1000; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+			c.evictEntries()
+		}
+		for _, n := range lir {
+			assert.True(t, n.isAlive())
+		}
+		p := c.policy.(*lirsPolicy)
+		assert.Equal(t, 99, p.lirWeight)
+		assert.Equal(t, 100, c.weightedSize)
+		assert.True(t, p.nonResident <= p.nonResidentMax)
+	})
+
+	t.Run("non-resident entry is promoted", func(t *testing.T) {
+		c := policyCacheForTest(LIRSPolicy, 3)
+		p := c.policy.(*lirsPolicy)
+		a := putNodeForTest(c, "a")
+		putNodeForTest(c, "b")
+		// HIR entries
+		putNodeForTest(c, "c")
+		d := putNodeForTest(c, "d")
+		c.evictEntries()
+		assert.Nil(t, p.ghosts["c"].Value)
+		assert.Equal(t, 1, p.nonResident)
+
+		// c is re-referenced while in S, so its reuse distance is smaller than the bottom LIR entry a
+		n := putNodeForTest(c, "c")
+		assert.True(t, p.isLIR(n))
+		assert.NotContains(t, p.ghosts, "c")
+		assert.Equal(t, 0, p.nonResident)
+		assert.False(t, p.isLIR(a))
+		assert.True(t, p.isHIR(a))
+		assert.Equal(t, 2, p.lirWeight)
+		c.evictEntries()
+		// the resident HIR entries are evicted in FIFO order
+		assert.False(t, d.isAlive())
+		assert.False(t, p.isHIR(d))
+		assert.True(t, a.isAlive())
+		assert.Equal(t, 3, c.weightedSize)
+	})
+
+	t.Run("resident entry re-added before the prior node is removed", func(t *testing.T) {
+		c := policyCacheForTest(LIRSPolicy, 10)
+		p := c.policy.(*lirsPolicy)
+		a := putNodeForTest(c, "a")
+		putNodeForTest(c, "b")
+		assert.Equal(t, 2, p.lirWeight)
+
+		// the replacing node is added before the prior one is removed
+		n := &Node{Key: []byte("a"), weight: 3}
+		p.OnAdd(n)
+		assert.True(t, p.isLIR(n))
+		assert.Equal(t, 5, p.lirWeight)
+		p.OnRemove(a)
+		assert.True(t, p.isLIR(n))
+		assert.Equal(t, 4, p.lirWeight)
+		p.OnRemove(n)
+		assert.Equal(t, 1, p.lirWeight)
+		assert.Equal(t, 1, p.stack.Size())
+		assert.Equal(t, 0, len(p.ghosts))
+	})
+
+	t.Run("HIR entry re-added before the prior node is removed", func(t *testing.T) {
+		c := policyCacheForTest(LIRSPolicy, 3)
+		p := c.policy.(*lirsPolicy)
+		putNodeForTest(c, "a")
+		putNodeForTest(c, "b")
+		h := putNodeForTest(c, "h")
+		assert.True(t, p.isHIR(h))
+
+		n := &Node{Key: []byte("h"), weight: 1}
+		p.OnAdd(n)
+		assert.True(t, p.isLIR(n))
+		// the prior node stays in Q, but is no more in S
+		p.OnRemove(h)
+		assert.False(t, p.isHIR(h))
+		assert.True(t, p.isLIR(n))
+		assert.Equal(t, 0, p.nonResident)
+		assert.Equal(t, 0, len(p.ghosts))
+	})
+
+	t.Run("HIR access in S promotes", func(t *testing.T) {
+		c := policyCacheForTest(LIRSPolicy, 3)
+		p := c.policy.(*lirsPolicy)
+		a := putNodeForTest(c, "a")
+		putNodeForTest(c, "b")
+		h := putNodeForTest(c, "h")
+		assert.False(t, p.isLIR(h))
+		c.onAccess(h)
+		assert.True(t, p.isLIR(h))
+		assert.False(t, p.isHIR(h))
+		assert.False(t, p.isLIR(a))
+		assert.Equal(t, 2, p.lirWeight)
+	})
+}
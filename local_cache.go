@@ -1,9 +1,11 @@
 package cocoa
 
 import (
-	"math/rand"
+	"io"
 	"runtime"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -63,7 +65,7 @@ func (t *PerformCleanupTask) run() {
 	if t.cache == nil {
 		return
 	}
-	t.cache.maintenance()
+	t.cache.performCleanUp(nil)
 }
 
 var (
@@ -72,10 +74,18 @@ var (
 )
 
 const (
-	//The number of attempts to insert into the write buffer before yielding.
-	WriteBufferRetries = 100
+	// The queue size of the default executor.
+	defaultExecutorQueueSize = 1024
 )
 
+// removalNotification is a removal found by the maintenance, which is sent after the eviction lock is
+// released.
+type removalNotification struct {
+	key   []byte
+	value interface{}
+	cause RemovalCause
+}
+
 // BoundedLocalCache is the local bounded cache. the eviction strategy supports
 // 1. size-based eviction
 //
@@ -83,31 +93,144 @@ const (
 type BoundedLocalCache struct {
 	data *SegmentHashMap
 
-	windowDeque    *AccessOrderDeque
-	probationDeque *AccessOrderDeque
-	protectedDeque *AccessOrderDeque
+	// the page replacement policy
+	policy EvictionPolicy
+	// if the nodes are allocated with their links, false if the policy keeps no deque
+	linkedNodes bool
 
 	// the cache weighted size
 	weightedSize int
 	maximum      int
 
-	// the window deque weighted size
-	windowWeightedSize int
-	windowMaximum      int
-
-	// the protected deque weighted size
-	mainProtectedWeightedSize int
-	mainProtectedMaximum      int
-
-	readBuffer  *ringBuffer
-	writeBuffer *ringBuffer
+	readBuffer  *stripedBuffer
+	writeBuffer *writeBuffer
 
 	enableEvict AtomicBool
 
 	sketch *FrequencySketch
-	//
-	evictExecChan chan PerformCleanupTask
-	drainState    *DrainState
+	// determines if a candidate of the window is admitted into the main space
+	admission AdmissionPolicy
+	// if the admission policy is the default TinyLFU on the sketch
+	sketchAdmission bool
+	// weighs the entries, nil if every entry weighs 1
+	weigher Weigher
+	// tracks the hottest keys, nil if disabled
+	hotKeys *hotKeys
+	// estimates the hit rates of the other sizes, nil if disabled
+	curve *hitRateCurve
+	// counts the distinct keys read and written in a rotating window
+	distinctKeys *distinctKeys
+
+	// executes the maintenance and the removal notifications
+	executor Executor
+	// if the executor runs the functions on the caller's goroutine
+	callerRuns      bool
+	removalListener RemovalListener
+	// the time source of the timestamps on the nodes
+	ticker     Ticker
+	drainState *DrainState
+
+	// the duration after the last access that an entry expires, zero means never
+	expireAfterAccess time.Duration
+	// schedules the maintenance of an idle cache, nil if no Scheduler is configured
+	pacer           *pacer
+	cleanupInterval time.Duration
+	// bounds the work of a maintenance run
+	budget maintenanceBudget
+	// guards the page replacement policy, held while performing the maintenance
+	evictionLock sync.Mutex
+	// the removals of the running maintenance, guarded by the eviction lock
+	pendingRemovals []removalNotification
+	// expires the entries of a policy without access order, guarded by the eviction lock
+	expirationScan expirationScan
+}
+
+// NewBoundedLocalCache returns a cache that evicts entries when the number of entries exceeds maximum,
+// with the default options of CacheBuilder.
+func NewBoundedLocalCache(maximum int) *BoundedLocalCache {
+	return NewCacheBuilder().MaximumSize(maximum).Build()
+}
+
+// newBoundedLocalCache returns the cache configured by the builder.
+// The window space holds 1% of the maximum, and the protected space holds 80% of the main space.
+func newBoundedLocalCache(b *CacheBuilder) *BoundedLocalCache {
+	maximum := b.maximum
+	// the sketch of a weighted cache starts small and grows with the number of entries
+	sketchCapacity := maximum
+	if b.weigher != nil {
+		sketchCapacity = 0
+	}
+	c := &BoundedLocalCache{
+		data:              newSegmentHashMap(),
+		maximum:           maximum,
+		readBuffer:        newStripedBuffer(),
+		writeBuffer:       newWriteBuffer(WriteBufferMaxCapacity),
+		sketch:            newFrequencySketch(sketchCapacity, b.sketchLayout),
+		admission:         b.admission,
+		weigher:           b.weigher,
+		executor:          b.executor,
+		removalListener:   b.removalListener,
+		ticker:            b.ticker,
+		expireAfterAccess: b.expireAfterAccess,
+		cleanupInterval:   b.cleanupInterval,
+		budget: maintenanceBudget{
+			maxDrainTasks: b.maxDrainTasks,
+			maxEvictions:  b.maxEvictions,
+			maxTime:       b.maxMaintenanceTime,
+		},
+		drainState: new(DrainState),
+	}
+	if c.executor == nil {
+		c.executor = DefaultExecutor()
+	}
+	_, c.callerRuns = c.executor.(callerRunsExecutor)
+	if c.ticker == nil {
+		c.ticker = SystemTicker()
+	}
+	if b.doorkeeper {
+		c.sketch.enableDoorkeeper()
+	}
+	if c.admission == nil {
+		c.admission = NewTinyLFUAdmission(c.sketch)
+		c.sketchAdmission = true
+	}
+	c.policy = newEvictionPolicy(b, c)
+	_, unlinked := c.policy.(unlinkedNodes)
+	c.linkedNodes = !unlinked
+	if b.sampleMultiplier > 0 {
+		c.sketch.setSampleMultiplier(b.sampleMultiplier)
+	}
+	if b.decayInterval > 0 {
+		c.sketch.setDecayInterval(b.decayInterval, c.ticker)
+	}
+	if b.hotKeys > 0 {
+		c.hotKeys = newHotKeys(b.hotKeys)
+	}
+	c.distinctKeys = newDistinctKeys(b.distinctKeysWindow, c.ticker)
+	if b.curveSampleRate > 0 {
+		c.curve = newHitRateCurve(b.curveSampleRate, b.curveSamples)
+	}
+	c.budget.ticker = c.ticker
+	if c.budget.maxDrainTasks <= 0 {
+		c.budget.maxDrainTasks = WriteBufferMaxCapacity
+	}
+	if b.scheduler != nil {
+		c.pacer = newPacer(b.scheduler)
+	}
+	c.enableEvict.Set(true)
+	return c
+}
+
+// weigh returns the weight of the entry, 1 if the cache has no weigher.
+func (c *BoundedLocalCache) weigh(key []byte, value interface{}) int {
+	if c.weigher == nil {
+		return 1
+	}
+	weight := c.weigher(key, value)
+	if weight < 0 {
+		panic("cocoa: negative weight")
+	}
+	return weight
 }
 
 // enableEvict returns if the cache evicts entries due to a maximum size or weight threshold.
@@ -119,33 +242,48 @@ func (c *BoundedLocalCache) Put(key []byte, value interface{}) {
 	if len(key) == 0 {
 		return
 	}
-	seg := c.data.getSegment(c.data.hash(key))
+	now := c.ticker.Read()
+	h := c.data.hash(key)
+	c.distinctKeys.record(h, now)
+	if c.curve != nil {
+		c.curve.record(key, h, false)
+	}
+	seg := c.data.getSegment(h)
 	seg.mux.Lock()
+	// the weight is computed under the lock, so the weight differences of the updates sum up to the weight
+	// of the last value in whatever order their tasks run
+	weight := c.weigh(key, value)
 	priorNode, existed := seg.data[*bytesToString(key)]
-	if !existed {
-		node := &Node{
-			Key:     key,
-			Value:   value,
-			weight:  1,
-			prev:    nil,
-			next:    nil,
-			dequeIn: Window,
-		}
+	expired := existed && c.hasExpired(priorNode, now)
+	if !existed || expired {
+		node := newNode(key, value, weight, now, c.linkedNodes)
 		seg.data[*bytesToString(key)] = node
+		if expired {
+			// the node stays in the page replacement policy until the DeleteTask runs
+			priorNode.retire()
+		}
 		seg.mux.Unlock()
+		if expired {
+			c.afterExpiredReplaced(priorNode)
+		}
 		c.afterWrite(&AddTask{
-			c:      c,
-			node:   node,
-			weight: 1,
+			c:    c,
+			node: node,
 		})
 	} else {
+		oldValue := priorNode.Value
+		weightDiff := weight - priorNode.valueWeight
 		priorNode.Value = value
+		priorNode.valueWeight = weight
+		priorNode.setWriteTime(now)
+		priorNode.setAccessTime(now)
 		seg.mux.Unlock()
 		c.afterWrite(&UpdateTask{
 			c:          c,
 			node:       priorNode,
-			weightDiff: 0,
+			weightDiff: weightDiff,
 		})
+		c.notifyRemoval(key, oldValue, Replaced)
 	}
 }
 
@@ -155,45 +293,79 @@ func (c *BoundedLocalCache) PutIfAbsent(key []byte, value interface{}) interface
 	if len(key) == 0 {
 		panic("key is empty.")
 	}
-	seg := c.data.getSegment(c.data.hash(key))
+	now := c.ticker.Read()
+	h := c.data.hash(key)
+	c.distinctKeys.record(h, now)
+	if c.curve != nil {
+		c.curve.record(key, h, false)
+	}
+	seg := c.data.getSegment(h)
 	seg.mux.Lock()
 	priorNode, existed := seg.data[*bytesToString(key)]
-	if !existed {
-		node := &Node{
-			Key:     key,
-			Value:   value,
-			weight:  1,
-			prev:    nil,
-			next:    nil,
-			dequeIn: Window,
-		}
+	// an expired entry is absent, even though the maintenance has not removed it yet
+	expired := existed && c.hasExpired(priorNode, now)
+	if !existed || expired {
+		node := newNode(key, value, c.weigh(key, value), now, c.linkedNodes)
 		seg.data[*bytesToString(key)] = node
+		if expired {
+			// the node stays in the page replacement policy until the DeleteTask runs
+			priorNode.retire()
+		}
 		seg.mux.Unlock()
+		if expired {
+			c.afterExpiredReplaced(priorNode)
+		}
 		c.afterWrite(&AddTask{
-			c:      c,
-			node:   node,
-			weight: 1,
+			c:    c,
+			node: node,
 		})
 		return nil
 	} else {
-		return priorNode
+		seg.mux.Unlock()
+		return priorNode.Value
 	}
 }
 
 func (c *BoundedLocalCache) Get(key []byte) (value interface{}) {
-	node, existed := c.data.Get(key)
+	if len(key) == 0 {
+		return nil
+	}
+	h := c.data.hash(key)
+	now := c.ticker.Read()
+	c.distinctKeys.record(h, now)
+	if c.curve != nil {
+		c.curve.record(key, h, true)
+	}
+	node, existed := c.data.getSegment(h).Get(key)
 	if !existed {
 		return nil
 	}
+	if c.hasExpired(node, now) {
+		// the expired entry will be removed by the maintenance
+		c.scheduleDrainBuffers()
+		return nil
+	}
+	node.setAccessTime(now)
 	c.afterRead(node)
 	return node.Value
 }
 
 func (c *BoundedLocalCache) Delete(key []byte) interface{} {
-	prior := c.data.Remove(key)
-	if prior == nil {
+	if len(key) == 0 {
 		return nil
 	}
+	seg := c.data.getSegment(c.data.hash(key))
+	seg.mux.Lock()
+	prior, existed := seg.data[*bytesToString(key)]
+	if !existed {
+		seg.mux.Unlock()
+		return nil
+	}
+	delete(seg.data, *bytesToString(key))
+	// the node stays in the page replacement policy until the DeleteTask runs
+	prior.retire()
+	seg.mux.Unlock()
+	c.notifyRemoval(key, prior.Value, Explicit)
 	c.afterWrite(&DeleteTask{
 		c:    c,
 		node: prior,
@@ -201,6 +373,16 @@ func (c *BoundedLocalCache) Delete(key []byte) interface{} {
 	return prior.Value
 }
 
+// afterExpiredReplaced notifies the removal of the expired node, which was retired for a new node of the key,
+// and removes it from the page replacement policy.
+func (c *BoundedLocalCache) afterExpiredReplaced(prior *Node) {
+	c.notifyRemoval(prior.Key, prior.Value, Expired)
+	c.afterWrite(&DeleteTask{
+		c:    c,
+		node: prior,
+	})
+}
+
 func (c *BoundedLocalCache) Contains(key []byte) (ok bool) {
 	return c.data.Contains(key)
 }
@@ -209,19 +391,98 @@ func (c *BoundedLocalCache) Size() int {
 	return c.data.Len()
 }
 
+// CleanUp performs the pending maintenance work on the caller's goroutine: the buffered reads and writes
+// are applied to the policy, followed by the expiration and the eviction.
+func (c *BoundedLocalCache) CleanUp() {
+	c.performCleanUp(nil)
+}
+
+// HotKeys returns at most k hottest keys recently accessed with their estimated frequencies, the hottest
+// first. It returns nil unless the cache is built with TrackHotKeys.
+func (c *BoundedLocalCache) HotKeys(k int) []HotKey {
+	if c.hotKeys == nil {
+		return nil
+	}
+	c.evictionLock.Lock()
+	defer c.evictionLock.Unlock()
+	return c.hotKeys.top(k, c.sketch)
+}
+
+// HitRateCurve returns the estimated hit rates of the reads if the cache were an LRU cache of the sizes, in
+// the number of entries, so the maximum can be sized without experiments. It returns nil unless the cache
+// is built with TrackHitRateCurve.
+func (c *BoundedLocalCache) HitRateCurve(sizes []int) []HitRatePoint {
+	if c.curve == nil {
+		return nil
+	}
+	return c.curve.hitRates(sizes)
+}
+
+// EstimatedDistinctKeys returns the estimated number of the distinct keys read or written in the last
+// window, which is set by DistinctKeysWindow, with an error of about 2%. The window rotates by quarters, so
+// the estimate covers the last three quarters and the elapsed part of the current one.
+func (c *BoundedLocalCache) EstimatedDistinctKeys() int64 {
+	return c.distinctKeys.estimate()
+}
+
+// SaveFrequencySketch writes the frequency history of the cache to w, with a version header, so that it can
+// be restored by LoadFrequencySketch after a restart.
+func (c *BoundedLocalCache) SaveFrequencySketch(w io.Writer) error {
+	c.evictionLock.Lock()
+	data := c.sketch.marshalBinary()
+	c.evictionLock.Unlock()
+	_, err := w.Write(data)
+	return err
+}
+
+// LoadFrequencySketch restores the frequency history written by SaveFrequencySketch. The saved sketch must
+// have the same table size and layout, i.e. be saved by a cache built with the same maximum size and layout.
+// The sketch of a weighted cache grows with the entries, so it takes the saved table size.
+func (c *BoundedLocalCache) LoadFrequencySketch(r io.Reader) error {
+	// the table length of an unweighted cache and the layout are fixed since the cache is built
+	length := 0
+	if c.weigher == nil {
+		length = len(c.sketch.table)
+	}
+	data, err := readSketch(r, length, c.sketch.layout)
+	if err != nil {
+		return err
+	}
+	c.evictionLock.Lock()
+	defer c.evictionLock.Unlock()
+	return c.sketch.unmarshalBinary(data)
+}
+
+// performCleanUp performs the maintenance work on the caller's goroutine, blocking until the eviction lock
+// is acquired. The task is run after the pending writes in the write buffer, or enqueued behind them if the
+// budget stops the draining, so its order is kept.
+// The removals are notified after the lock is released, so a listener may write to the cache.
 func (c *BoundedLocalCache) performCleanUp(t task) {
-	c.evictExecChan <- PerformCleanupTask{
-		cache: c,
+	c.evictionLock.Lock()
+	c.maintenance(t)
+	removals := c.pendingRemovals
+	c.pendingRemovals = nil
+	c.evictionLock.Unlock()
+	for _, r := range removals {
+		c.notifyRemoval(r.key, r.value, r.cause)
+	}
+	// an executor running on the caller would re-enter on the same stack, so the remaining work is left
+	// Required for the next read or write
+	if c.drainState.get() == Required && !c.callerRuns {
+		c.scheduleDrainBuffers()
 	}
-	t.run()
 }
 
 //======================================================================================================================
 // Performs the pending maintenance work and sets the state flags during processing to avoid
 // excess scheduling attempts. The read buffer and write buffer are drained,
 // followed by expiration, and size-based eviction.
-func (c *BoundedLocalCache) maintenance() {
+// The task, if not nil, is run once the write buffer is drained, or enqueued behind the remaining writes.
+// The work is bounded by the maintenance budget, the remaining work is rescheduled.
+// Caller must hold the eviction lock.
+func (c *BoundedLocalCache) maintenance(t task) {
 	c.drainState.set(ProcessingToIdle)
+	c.budget.start()
 	defer func() {
 		// 1. after eviction, the status is not ProcessingToIdle, so need to continue drain buffer, mark as Required
 		// 2. after eviction, the status is ProcessingToIdle, fail to cas update status to Idle, so need to continue drain buffer, mark as Required
@@ -231,186 +492,267 @@ func (c *BoundedLocalCache) maintenance() {
 	}()
 
 	c.drainReadBuffer()
-	c.drainWriteBuffer()
+	drained := c.drainWriteBuffer()
+	if t != nil {
+		c.runAfterWrites(t, drained)
+	}
 
+	c.expireEntries()
 	c.evictEntries()
+	c.scheduleMaintenance()
 }
 
 func (c *BoundedLocalCache) drainReadBuffer() {
-	c.readBuffer.drainBuf()
+	c.readBuffer.drainTo(c.onAccess)
 }
 
-func (c *BoundedLocalCache) drainWriteBuffer() {
-	for i := 0; i < WriteBufferMaxCapacity; i++ {
+// drainWriteBuffer runs the pending write tasks within the budget, and returns if the write buffer is drained.
+func (c *BoundedLocalCache) drainWriteBuffer() bool {
+	for i := 0; i < c.budget.maxDrainTasks; i++ {
 		t := c.writeBuffer.poll()
 		if t == nil {
-			return
+			return true
 		}
 		t.run()
+		// reading the ticker is not free, check the time slice periodically
+		if i&63 == 63 && c.budget.timeExceeded() {
+			break
+		}
 	}
 	// the write buffer has not been drained,
 	// the state machine of drainState is switched to the ProcessingToRequired
-	c.drainState.set(ProcessingToRequired)
+	c.yieldMaintenance()
+	return false
 }
 
-func (c *BoundedLocalCache) evictEntries() {
-	if !c.EnableEvict() {
-		return
+// runAfterWrites runs the task if the write buffer is drained. Otherwise the task is enqueued behind the
+// remaining writes, which are drained beyond the budget only while the buffer stays full.
+func (c *BoundedLocalCache) runAfterWrites(t task, drained bool) {
+	for !drained || c.writeBuffer.size() > 0 {
+		if c.writeBuffer.offer(t) {
+			c.yieldMaintenance()
+			return
+		}
+		drained = c.drainWriteBuffer()
 	}
-	candidateNum := c.evictFromWindow()
-	c.evictFromMain(candidateNum)
+	t.run()
 }
 
-//  Attempts to evict the entry. A removal due to size may be ignored if the entry was updated and is no longer eligible for eviction.
-func (c *BoundedLocalCache) evictEntry(node *Node) {
-	if node == nil || len(node.Key) == 0 || !c.EnableEvict() {
+// yieldMaintenance marks that the maintenance stops before finishing the work, so it is rescheduled.
+func (c *BoundedLocalCache) yieldMaintenance() {
+	c.drainState.set(ProcessingToRequired)
+}
+
+// hasExpired returns if the entry was not accessed within the expiration duration.
+func (c *BoundedLocalCache) hasExpired(n *Node, now int64) bool {
+	return c.expireAfterAccess > 0 && now-n.getAccessTime() >= int64(c.expireAfterAccess)
+}
+
+// expireEntries evicts the expired entries from the head of each access order deque of the policy, or by
+// scanning the entries if the policy is not in access order.
+func (c *BoundedLocalCache) expireEntries() {
+	if c.expireAfterAccess <= 0 {
 		return
 	}
-	c.data.Remove(node.Key)
-	c.weightedSize -= node.weight
-	if node.inWindow() {
-		c.windowWeightedSize -= node.weight
-		c.windowDeque.Remove(node)
-	} else if node.inMainProbation() {
-		c.probationDeque.Remove(node)
-	} else {
-		c.mainProtectedWeightedSize -= node.weight
-		c.protectedDeque.Remove(node)
+	now := c.ticker.Read()
+	ordered, ok := c.policy.(accessOrdered)
+	if !ok {
+		c.expireEntriesByScan(now)
+		return
 	}
-	return
-}
-
-// Evicts entries from the window space into the main space while the window size exceeds a maximum.
-// return the number of candidate entries evicted from the window space
-func (c *BoundedLocalCache) evictFromWindow() int {
-	candidateNum := 0
-	node := c.accessOrderWindowDeque().GetFront()
-	for c.windowWeightedSize > c.windowMaximum {
-		if node == nil {
-			break
+	for _, deque := range ordered.accessOrderDeques() {
+		for node := deque.GetFront(); node != nil && c.hasExpired(node, now); {
+			if c.budget.evictionExhausted() {
+				c.yieldMaintenance()
+				return
+			}
+			next := node.nextNode()
+			c.evictEntry(node, Expired)
+			node = next
 		}
-		next := node.next
-		c.accessOrderWindowDeque().Remove(node)
-		c.accessOrderProbationDeque().PushBack(node)
-		candidateNum++
-		c.windowWeightedSize = c.windowWeightedSize - node.weight
-		node = next
 	}
-	return candidateNum
 }
 
-// Evicts entries from the main space if the cache exceeds the maximum capacity. The main space
-// determines whether admitting an entry (coming from the window space) is preferable to retaining
-// the eviction policy's victim. This is decision is made using a frequency filter so that the
-// least frequently used entry is removed.
-//
-// The window space candidates were previously placed in the MRU position and the eviction
-// policy's victim is at the LRU position. The two ends of the queue are evaluated while an
-// eviction is required. The number of remaining candidates is provided and decremented on
-// eviction, so that when there are no more candidates the victim is evicted.
-func (c *BoundedLocalCache) evictFromMain(candidates int) {
-	victimQueue := Probation
-	victim := c.accessOrderProbationDeque().GetFront()
-	candidate := c.accessOrderProbationDeque().GetBack()
-
-	for c.weightedSize > c.maximum {
-		// Stop trying to evict candidates from window deque and always prefer the victim
-		if candidates == 0 {
-			candidate = nil
+// expirationScan is the state of the incremental scan expiring the entries of a policy without access
+// order, guarded by the eviction lock. A pass scans the segments from the cursor over many maintenance runs,
+// and the next pass starts once an entry may have expired since the last one.
+type expirationScan struct {
+	// the next segment of the pass in progress, zero if no pass is in progress
+	cursor int
+	// the time the pass in progress started
+	start int64
+	// the oldest access time seen by the pass in progress
+	oldest int64
+	// the time the next pass may find an expired entry
+	nextPass int64
+}
+
+// expireEntriesByScan evicts the expired entries found by scanning the segments from the cursor, within the
+// budget of the run.
+func (c *BoundedLocalCache) expireEntriesByScan(now int64) {
+	s := &c.expirationScan
+	if s.cursor == 0 {
+		if now < s.nextPass {
+			return
 		}
-		// Try evicting from the protected and window queues
-		if candidate == nil && victim == nil {
-			// Probation deque is empty now, try to evict from Protected deque
-			if victimQueue == Probation {
-				victim = c.accessOrderProtectedDeque().GetFront()
-				victimQueue = Protected
-				continue
-			} else if victimQueue == Protected {
-				// Both Probation deque and Protected deque are empty, try to evict from Window deque
-				victim = c.accessOrderWindowDeque().GetFront()
-				victimQueue = Window
-				continue
+		s.start, s.oldest = now, now
+	}
+	var expired []*Node
+	for s.cursor < len(c.data.table) {
+		if c.budget.scanExhausted() {
+			c.yieldMaintenance()
+			return
+		}
+		seg := c.data.table[s.cursor]
+		expired = expired[:0]
+		seg.mux.RLock()
+		for _, node := range seg.data {
+			if c.hasExpired(node, now) {
+				expired = append(expired, node)
+			} else if accessTime := node.getAccessTime(); accessTime < s.oldest {
+				s.oldest = accessTime
 			}
-			// The pending operations will adjust the size to reflect the correct weight
-			break
 		}
-
-		// Evict immediately if only one of the entries is present
-		if victim == nil {
-			previous := candidate.prev
-			evict := candidate
-			candidate = previous
-			candidates--
-			c.evictEntry(evict)
-			continue
-		} else if candidate == nil {
-			// candidate is nil, always prefer to evict victim from Probation or Protected
-			evict := victim
-			victim = victim.next
-			c.evictEntry(evict)
-			continue
+		c.budget.onScan(len(seg.data))
+		seg.mux.RUnlock()
+		for _, node := range expired {
+			if c.budget.evictionExhausted() {
+				// the segment is scanned again by the next run
+				c.yieldMaintenance()
+				return
+			}
+			c.evictEntry(node, Expired)
 		}
+		s.cursor++
+	}
+	// the entries not seen by the pass were accessed after it started
+	s.cursor = 0
+	s.nextPass = s.oldest + int64(c.expireAfterAccess)
+}
 
-		// Evict immediately if an entry was collected
-		victimKey := victim.Key
-		candidateKey := candidate.Key
-		if len(victimKey) == 0 {
-			evict := victim
-			victim = victim.next
-			c.evictEntry(evict)
-			continue
-		} else if len(candidateKey) == 0 {
-			candidates--
-			evict := candidate
-			candidate = candidate.prev
-			c.evictEntry(evict)
-			continue
+// nextExpirationDelay returns the duration until the earliest entry expires, or false if no entry will expire.
+func (c *BoundedLocalCache) nextExpirationDelay(now int64) (time.Duration, bool) {
+	if c.expireAfterAccess <= 0 {
+		return 0, false
+	}
+	ordered, ok := c.policy.(accessOrdered)
+	if !ok {
+		if c.expirationScan.cursor != 0 {
+			// the pass in progress continues as soon as possible
+			return 0, true
 		}
-		if candidate.weight > c.maximum {
-			candidates--
-			evict := candidate
-			candidate = candidate.prev
-			c.evictEntry(evict)
-			continue
+		if c.data.Len() == 0 {
+			return 0, false
 		}
-
-		// Evict the entry with the lowest frequency
-		candidates--
-		if c.admit(candidateKey, victimKey) {
-			evict := victim
-			victim = victim.next
-			c.evictEntry(evict)
-			candidate = candidate.prev
-		} else {
-			evict := candidate
-			candidate = candidate.prev
-			c.evictEntry(evict)
+		delay := time.Duration(c.expirationScan.nextPass - now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	var oldest []*Node
+	for _, deque := range ordered.accessOrderDeques() {
+		if node := deque.GetFront(); node != nil {
+			oldest = append(oldest, node)
+		}
+	}
+	delay, found := time.Duration(0), false
+	for _, node := range oldest {
+		d := time.Duration(node.getAccessTime() + int64(c.expireAfterAccess) - now)
+		if d < 0 {
+			d = 0
+		}
+		if !found || d < delay {
+			delay, found = d, true
 		}
 	}
-	return
+	return delay, found
 }
 
-// Determines if the candidate should be accepted into the main space, as determined by its
-// frequency relative to the victim. A small amount of randomness is used to protect against hash
-// collision attacks, where the victim's frequency is artificially raised so that no new entries
-// are admitted.
-// return if the candidate should be admitted and the victim rejected
-func (c *BoundedLocalCache) admit(candidateKey []byte, victimKey []byte) bool {
-	candidateFreq := c.sketch.frequency(candidateKey)
-	victimFreq := c.sketch.frequency(victimKey)
-	if candidateFreq > victimFreq {
-		return true
-	} else if candidateFreq <= 5 {
-		// candidateFreq<=victimFreq && candidateFreq <= 5
+// scheduleMaintenance arms the pacer for the next expiration deadline or the cleanup interval,
+// so the maintenance is performed even if the cache is idle.
+func (c *BoundedLocalCache) scheduleMaintenance() {
+	if c.pacer == nil {
+		return
+	}
+	now := c.ticker.Read()
+	delay, found := c.nextExpirationDelay(now)
+	if c.cleanupInterval > 0 && (!found || c.cleanupInterval < delay) {
+		delay, found = c.cleanupInterval, true
+	}
+	if !found {
+		c.pacer.stop()
+		return
+	}
+	c.pacer.schedule(now, delay, c.scheduleDrainBuffers)
+}
+
+func (c *BoundedLocalCache) evictEntries() {
+	if !c.EnableEvict() {
+		return
+	}
+	c.policy.Evict(c.evictionRequired, func(n *Node) {
+		c.evictEntry(n, Evicted)
+	})
+}
+
+// evictionRequired returns if the cache exceeds the maximum, and the eviction budget is not exhausted.
+func (c *BoundedLocalCache) evictionRequired() bool {
+	if c.weightedSize <= c.maximum {
+		return false
+	}
+	if c.budget.evictionExhausted() {
+		c.yieldMaintenance()
 		return false
 	}
-	random := rand.Int()
-	return (random & 127) == 0
+	return true
 }
 
-// onAccess updates the node's location in the page replacement policy
+//  Attempts to evict the entry. A removal due to size may be ignored if the entry was updated and is no longer eligible for eviction.
+func (c *BoundedLocalCache) evictEntry(node *Node, cause RemovalCause) {
+	if node == nil || len(node.Key) == 0 || !c.EnableEvict() {
+		return
+	}
+	seg := c.data.getSegment(c.data.hash(node.Key))
+	seg.mux.Lock()
+	if node.isDead() {
+		seg.mux.Unlock()
+		// a dead node was removed from the policy, remove it again so the policy never offers it twice
+		c.policy.OnRemove(node)
+		return
+	}
+	// the key may be mapped to a new node if this one was retired
+	wasAlive := node.isAlive()
+	if current, existed := seg.data[*bytesToString(node.Key)]; existed && current == node {
+		delete(seg.data, *bytesToString(node.Key))
+	}
+	node.die()
+	value := node.Value
+	seg.mux.Unlock()
+	c.budget.onEviction()
+	if wasAlive && c.removalListener != nil {
+		// the retired node has been notified by Delete
+		c.pendingRemovals = append(c.pendingRemovals, removalNotification{key: node.Key, value: value, cause: cause})
+	}
+
+	if node.accounted {
+		c.weightedSize -= node.weight
+	}
+	c.policy.OnRemove(node)
+}
+
+// recordAccess records an access of the key into the admission policy, and into the cache's sketch unless
+// the admission policy is the default TinyLFU recording into it.
+func (c *BoundedLocalCache) recordAccess(key []byte) {
+	c.admission.Record(key)
+	if !c.sketchAdmission {
+		c.sketch.increment(key)
+	}
+}
+
+// onAccess records the access of the node, and updates its location in the page replacement policy
 func (c *BoundedLocalCache) onAccess(n *Node) {
-	if n == nil {
+	if n == nil || !n.isAlive() {
 		return
 	}
 	if !c.EnableEvict() {
@@ -420,19 +762,12 @@ func (c *BoundedLocalCache) onAccess(n *Node) {
 	if len(key) == 0 {
 		return
 	}
-	c.sketch.increment(key)
-
-	// update location
-	if n.inWindow() && c.windowDeque.Contains(n) {
-		c.windowDeque.MoveToBack(n)
-	} else if n.inMainProbation() && c.probationDeque.Contains(n) {
-		c.mainProtectedWeightedSize += n.weight
-		c.probationDeque.Remove(n)
-		c.protectedDeque.PushBack(n)
-		n.makeIn(Protected)
-	} else if n.inMainProtected() && c.protectedDeque.Contains(n) {
-		c.protectedDeque.MoveToBack(n)
+	c.recordAccess(key)
+	if c.hotKeys != nil {
+		c.hotKeys.record(key, c.sketch.frequency(key), c.sketch)
 	}
+
+	c.policy.OnAccess(n)
 }
 
 func (c *BoundedLocalCache) onWrite(p unsafe.Pointer) {
@@ -448,34 +783,21 @@ func (c *BoundedLocalCache) onWrite(p unsafe.Pointer) {
 }
 
 //======================================================================================================================
-func (c *BoundedLocalCache) accessOrderWindowDeque() *AccessOrderDeque {
-	return c.windowDeque
-}
-func (c *BoundedLocalCache) accessOrderProbationDeque() *AccessOrderDeque {
-	return c.probationDeque
-}
-func (c *BoundedLocalCache) accessOrderProtectedDeque() *AccessOrderDeque {
-	return c.protectedDeque
-}
-
 func (c *BoundedLocalCache) afterRead(node *Node) {
 	// Might lose some read record if readBuffer.offer return failed
-	delayable := c.readBuffer.offer(unsafe.Pointer(node)) != full
+	delayable := c.readBuffer.offer(node) != full
 	if c.shouldDrainBuffers(delayable) {
 		c.scheduleDrainBuffers()
 	}
 }
 
 func (c *BoundedLocalCache) afterWrite(t task) {
-	for i := 0; i < WriteBufferRetries; i++ {
-		if c.writeBuffer.offer(unsafe.Pointer(&t)) == success {
-			c.scheduleAfterWrite()
-			return
-		}
-		c.scheduleDrainBuffers()
+	if c.writeBuffer.offer(t) {
+		c.scheduleAfterWrite()
+		return
 	}
 
-	// perform task directly
+	// the write buffer is full, apply back-pressure by performing the task and maintenance directly
 	c.performCleanUp(t)
 }
 
@@ -524,19 +846,19 @@ func (c *BoundedLocalCache) scheduleDrainBuffers() {
 		// processing, return directly.
 		return
 	}
-	if status.casDrainStatus(status, ProcessingToIdle) {
-		c.evictExecChan <- PerformCleanupTask{cache: c}
+	if c.drainState.casDrainStatus(status, ProcessingToIdle) {
+		t := &PerformCleanupTask{cache: c}
+		c.executor.Execute(t.run)
 	}
 }
 
-// async to clean up cache
-// Caller must guarantee only one async goroutine to execute this function.
-func (c *BoundedLocalCache) asyncCleanUp() {
-	for {
-		task := <-c.evictExecChan
-		task.run()
-		if c.drainState.get() == Required {
-			c.scheduleDrainBuffers()
-		}
+// notifyRemoval sends the removal notification to the listener by the executor.
+func (c *BoundedLocalCache) notifyRemoval(key []byte, value interface{}, cause RemovalCause) {
+	if c.removalListener == nil {
+		return
 	}
+	listener := c.removalListener
+	c.executor.Execute(func() {
+		listener(key, value, cause)
+	})
 }
@@ -1,8 +1,16 @@
 package cocoa
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/louyuting/cocoa/workload"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDrainStatus(t *testing.T) {
@@ -12,3 +20,485 @@ func TestDrainStatus(t *testing.T) {
 		fmt.Println(s.get() >= ProcessingToIdle)
 	})
 }
+
+func wTinyLFUForTest(c *BoundedLocalCache) *wTinyLFUPolicy {
+	return c.policy.(*wTinyLFUPolicy)
+}
+
+func putNodeForTest(c *BoundedLocalCache, key string) *Node {
+	n := newNode([]byte(key), key, 1, 0, c.linkedNodes)
+	seg := c.data.getSegment(c.data.hash(n.Key))
+	seg.mux.Lock()
+	seg.data[key] = n
+	seg.mux.Unlock()
+	(&AddTask{c: c, node: n}).run()
+	return n
+}
+
+// deleteNodeForTest removes the node from the map as Delete does, without scheduling the DeleteTask.
+func deleteNodeForTest(c *BoundedLocalCache, n *Node) {
+	seg := c.data.getSegment(c.data.hash(n.Key))
+	seg.mux.Lock()
+	delete(seg.data, string(n.Key))
+	n.retire()
+	seg.mux.Unlock()
+}
+
+func TestNodeLifecycle(t *testing.T) {
+	t.Run("TestDeletedNodeNotResurrected", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n := putNodeForTest(c, "k1")
+		assert.True(t, n.isAlive())
+		assert.True(t, wTinyLFUForTest(c).windowDeque.Contains(n))
+
+		deleteNodeForTest(c, n)
+		assert.True(t, n.isRetired())
+		assert.False(t, c.Contains(n.Key))
+
+		(&DeleteTask{c: c, node: n}).run()
+		assert.True(t, n.isDead())
+		assert.True(t, wTinyLFUForTest(c).windowDeque.IsEmpty())
+		assert.Equal(t, 0, c.weightedSize)
+		assert.Equal(t, 0, wTinyLFUForTest(c).windowWeightedSize)
+
+		// stale tasks still sitting in the buffers
+		(&UpdateTask{c: c, node: n, weightDiff: 1}).run()
+		c.onAccess(n)
+		assert.True(t, wTinyLFUForTest(c).windowDeque.IsEmpty())
+		assert.Equal(t, 0, c.weightedSize)
+	})
+
+	t.Run("TestAddTaskOfRetiredNode", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n := &Node{Key: []byte("k1"), weight: 1}
+		n.retire()
+		(&AddTask{c: c, node: n}).run()
+		assert.True(t, wTinyLFUForTest(c).windowDeque.IsEmpty())
+		(&DeleteTask{c: c, node: n}).run()
+		assert.True(t, n.isDead())
+		assert.Equal(t, 0, c.weightedSize)
+		assert.Equal(t, 0, wTinyLFUForTest(c).windowWeightedSize)
+	})
+
+	t.Run("TestDeleteTaskBeforeAddTask", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n := &Node{Key: []byte("k1"), weight: 5}
+		seg := c.data.getSegment(c.data.hash(n.Key))
+		seg.data["k1"] = n
+		deleteNodeForTest(c, n)
+
+		(&DeleteTask{c: c, node: n}).run()
+		(&AddTask{c: c, node: n}).run()
+		assert.True(t, n.isDead())
+		assert.True(t, wTinyLFUForTest(c).windowDeque.IsEmpty())
+		assert.Equal(t, 0, c.weightedSize)
+		assert.Equal(t, 0, wTinyLFUForTest(c).windowWeightedSize)
+	})
+
+	t.Run("TestUpdateTaskBeforeAddTask", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n := &Node{Key: []byte("k1"), weight: 1}
+		seg := c.data.getSegment(c.data.hash(n.Key))
+		seg.data["k1"] = n
+
+		(&UpdateTask{c: c, node: n, weightDiff: 2}).run()
+		assert.Equal(t, 0, c.weightedSize)
+		(&AddTask{c: c, node: n}).run()
+		assert.Equal(t, 3, c.weightedSize)
+		(&DeleteTask{c: c, node: n}).run()
+		assert.Equal(t, 0, c.weightedSize)
+	})
+
+	t.Run("TestUpdateTasksOutOfOrder", func(t *testing.T) {
+		weigher := func(key []byte, value interface{}) int { return value.(int) }
+		c := NewCacheBuilder().MaximumWeight(100).Weigher(weigher).Executor(NewCallerRunsExecutor()).Build()
+		c.Put([]byte("k1"), 1)
+		c.CleanUp()
+		n := c.data.getSegment(c.data.hash([]byte("k1"))).data["k1"]
+
+		// the updates are written to the map in order, but their tasks run in the reverse order
+		var tasks []*UpdateTask
+		for _, weight := range []int{5, 2} {
+			weightDiff := weight - n.valueWeight
+			n.valueWeight = weight
+			tasks = append(tasks, &UpdateTask{c: c, node: n, weightDiff: weightDiff})
+		}
+		tasks[1].run()
+		tasks[0].run()
+		assert.Equal(t, 2, n.weight)
+		assert.Equal(t, 2, c.weightedSize)
+	})
+
+	t.Run("TestEvictedNodeNotResurrected", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n := putNodeForTest(c, "k1")
+		c.evictEntry(n, Evicted)
+		assert.True(t, n.isDead())
+		assert.False(t, c.Contains(n.Key))
+		assert.True(t, wTinyLFUForTest(c).windowDeque.IsEmpty())
+
+		(&UpdateTask{c: c, node: n}).run()
+		(&DeleteTask{c: c, node: n}).run()
+		assert.True(t, wTinyLFUForTest(c).windowDeque.IsEmpty())
+		assert.Equal(t, 0, c.weightedSize)
+	})
+
+	t.Run("TestEvictRetiredNodeKeepsNewMapping", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n := putNodeForTest(c, "k1")
+		deleteNodeForTest(c, n)
+		n2 := putNodeForTest(c, "k1")
+		c.evictEntry(n, Evicted)
+		assert.True(t, n.isDead())
+		assert.True(t, n2.isAlive())
+		assert.True(t, c.Contains(n.Key))
+	})
+}
+
+func TestBoundedLocalCache_onAccess(t *testing.T) {
+	t.Run("TestReadPromotesProbationToProtected", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n1 := putNodeForTest(c, "k1")
+		n2 := putNodeForTest(c, "k2")
+		// the window holds a single entry, so n1 moves to the probation space
+		wTinyLFUForTest(c).evictFromWindow()
+		assert.True(t, n1.inMainProbation())
+		assert.True(t, wTinyLFUForTest(c).probationDeque.Contains(n1))
+		assert.True(t, n2.inWindow())
+
+		assert.True(t, c.readBuffer.offer(n1) == success)
+		c.drainReadBuffer()
+		assert.True(t, n1.inMainProtected())
+		assert.True(t, wTinyLFUForTest(c).probationDeque.IsEmpty())
+		assert.True(t, wTinyLFUForTest(c).protectedDeque.GetBack() == n1)
+		assert.Equal(t, 1, wTinyLFUForTest(c).mainProtectedWeightedSize)
+		assert.Equal(t, 2, c.sketch.frequency(n1.Key))
+	})
+
+	t.Run("TestReadOfDeletedNodeIgnored", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		n1 := putNodeForTest(c, "k1")
+		putNodeForTest(c, "k2")
+		wTinyLFUForTest(c).evictFromWindow()
+		deleteNodeForTest(c, n1)
+		(&DeleteTask{c: c, node: n1}).run()
+
+		assert.True(t, c.readBuffer.offer(n1) == success)
+		c.drainReadBuffer()
+		assert.True(t, wTinyLFUForTest(c).protectedDeque.IsEmpty())
+		assert.True(t, wTinyLFUForTest(c).probationDeque.IsEmpty())
+	})
+
+	t.Run("TestGetRecordsAccess", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		c.Put([]byte("k1"), 1)
+		assert.Equal(t, 1, c.Get([]byte("k1")))
+		assert.Nil(t, c.Get([]byte("k2")))
+	})
+}
+
+func TestBoundedLocalCache_concurrentWrites(t *testing.T) {
+	t.Run("TestWriteBurstDoesNotStall", func(t *testing.T) {
+		c := NewBoundedLocalCache(100)
+		wg := sync.WaitGroup{}
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 2000; i++ {
+					key := []byte(strconv.Itoa(g*2000 + i))
+					c.Put(key, i)
+					c.Get(key)
+					if i%3 == 0 {
+						c.Delete(key)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		assert.True(t, c.Size() > 0)
+	})
+}
+
+type removal struct {
+	key   string
+	value interface{}
+	cause RemovalCause
+}
+
+func TestBoundedLocalCache_removalListener(t *testing.T) {
+	t.Run("TestRemovalCauses", func(t *testing.T) {
+		removals := make([]removal, 0)
+		c := NewCacheBuilder().
+			MaximumSize(10).
+			Executor(NewCallerRunsExecutor()).
+			RemovalListener(func(key []byte, value interface{}, cause RemovalCause) {
+				removals = append(removals, removal{key: string(key), value: value, cause: cause})
+			}).
+			Build()
+
+		c.Put([]byte("k1"), 1)
+		c.Put([]byte("k1"), 2)
+		assert.Equal(t, []removal{{key: "k1", value: 1, cause: Replaced}}, removals)
+
+		assert.Equal(t, 2, c.Delete([]byte("k1")))
+		assert.Equal(t, removal{key: "k1", value: 2, cause: Explicit}, removals[1])
+
+		removals = removals[:0]
+		for i := 0; i < 20; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		assert.Equal(t, 10, c.Size())
+		assert.Equal(t, 10, len(removals))
+		for _, r := range removals {
+			assert.Equal(t, Evicted, r.cause)
+			assert.False(t, c.Contains([]byte(r.key)))
+		}
+	})
+
+	t.Run("TestPutOverExpiredEntry", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		removals := make([]removal, 0)
+		c := NewCacheBuilder().
+			MaximumSize(10).
+			Ticker(ticker).
+			ExpireAfterAccess(time.Minute).
+			Executor(NewCallerRunsExecutor()).
+			RemovalListener(func(key []byte, value interface{}, cause RemovalCause) {
+				removals = append(removals, removal{key: string(key), value: value, cause: cause})
+			}).
+			Build()
+
+		c.Put([]byte("k1"), 1)
+		ticker.Advance(time.Minute)
+		c.Put([]byte("k1"), 2)
+		assert.Equal(t, []removal{{key: "k1", value: 1, cause: Expired}}, removals)
+		assert.Equal(t, 2, c.Get([]byte("k1")))
+
+		// the maintenance neither expires the new entry nor notifies the replaced one again
+		c.CleanUp()
+		assert.Equal(t, 1, c.Size())
+		assert.Equal(t, 1, c.weightedSize)
+		assert.Equal(t, 1, len(removals))
+	})
+
+	t.Run("TestPutIfAbsentOverExpiredEntry", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		removals := make([]removal, 0)
+		c := NewCacheBuilder().
+			MaximumSize(10).
+			Ticker(ticker).
+			ExpireAfterAccess(time.Minute).
+			Executor(NewCallerRunsExecutor()).
+			RemovalListener(func(key []byte, value interface{}, cause RemovalCause) {
+				removals = append(removals, removal{key: string(key), value: value, cause: cause})
+			}).
+			Build()
+
+		assert.Nil(t, c.PutIfAbsent([]byte("k1"), 1))
+		assert.Equal(t, 1, c.PutIfAbsent([]byte("k1"), 2))
+		ticker.Advance(time.Minute)
+		assert.Nil(t, c.PutIfAbsent([]byte("k1"), 3))
+		assert.Equal(t, []removal{{key: "k1", value: 1, cause: Expired}}, removals)
+		assert.Equal(t, 3, c.Get([]byte("k1")))
+
+		c.CleanUp()
+		assert.Equal(t, 1, c.Size())
+		assert.Equal(t, 1, c.weightedSize)
+		assert.Equal(t, 1, len(removals))
+	})
+
+	t.Run("TestListenerWritesWithCallerRuns", func(t *testing.T) {
+		var c *BoundedLocalCache
+		reentered := false
+		c = NewCacheBuilder().
+			MaximumSize(10).
+			Executor(NewCallerRunsExecutor()).
+			RemovalListener(func(key []byte, value interface{}, cause RemovalCause) {
+				if reentered {
+					return
+				}
+				reentered = true
+				// enough writes to fill the write buffer, which runs the maintenance on this goroutine
+				for i := 0; i < 2*WriteBufferMaxCapacity; i++ {
+					c.Put([]byte("listener-"+strconv.Itoa(i)), i)
+				}
+			}).
+			Build()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 20; i++ {
+				c.Put([]byte(strconv.Itoa(i)), i)
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the listener deadlocked on the eviction lock")
+		}
+		assert.True(t, reentered)
+		c.CleanUp()
+		assert.Equal(t, 10, c.Size())
+	})
+}
+
+func TestBoundedLocalCache_ticker(t *testing.T) {
+	t.Run("TestNodeTimestamps", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		c := NewCacheBuilder().
+			MaximumSize(10).
+			Executor(NewCallerRunsExecutor()).
+			Ticker(ticker).
+			Build()
+
+		ticker.Advance(time.Second)
+		c.Put([]byte("k1"), 1)
+		n, _ := c.data.Get([]byte("k1"))
+		assert.Equal(t, int64(time.Second), n.getWriteTime())
+		assert.Equal(t, int64(time.Second), n.getAccessTime())
+
+		ticker.Advance(time.Second)
+		c.Get([]byte("k1"))
+		assert.Equal(t, int64(time.Second), n.getWriteTime())
+		assert.Equal(t, int64(2*time.Second), n.getAccessTime())
+
+		ticker.Advance(time.Second)
+		c.Put([]byte("k1"), 2)
+		assert.Equal(t, int64(3*time.Second), n.getWriteTime())
+		assert.Equal(t, int64(3*time.Second), n.getAccessTime())
+	})
+}
+
+func TestBoundedLocalCache_frequencySketchPersistence(t *testing.T) {
+	t.Run("restore", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		key := []byte("hot")
+		for i := 0; i < 5; i++ {
+			c.sketch.increment(key)
+		}
+		var buf bytes.Buffer
+		assert.Nil(t, c.SaveFrequencySketch(&buf))
+
+		restored := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		assert.Nil(t, restored.LoadFrequencySketch(&buf))
+		assert.Equal(t, 5, restored.sketch.frequency(key))
+		assert.Equal(t, c.sketch.size, restored.sketch.size)
+		assert.Equal(t, c.sketch.sampleSize, restored.sketch.sampleSize)
+	})
+
+	t.Run("restore in another process", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		key := []byte("hot")
+		for i := 0; i < 5; i++ {
+			c.sketch.increment(key)
+		}
+		var buf bytes.Buffer
+		assert.Nil(t, c.SaveFrequencySketch(&buf))
+
+		// another process seeds the hash of the map differently
+		seeded := hashkey
+		defer func() { hashkey = seeded }()
+		before := hash(key)
+		for i := range hashkey {
+			hashkey[i] += 2
+		}
+		assert.NotEqual(t, before, hash(key))
+		restored := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		assert.Nil(t, restored.LoadFrequencySketch(&buf))
+		assert.Equal(t, 5, restored.sketch.frequency(key))
+		assert.Equal(t, 0, restored.sketch.frequency([]byte("cold")))
+	})
+
+	t.Run("restore a weighted cache", func(t *testing.T) {
+		weigher := func(key []byte, value interface{}) int { return 1 }
+		c := NewCacheBuilder().MaximumWeight(1000).Weigher(weigher).Executor(NewCallerRunsExecutor()).Build()
+		for i := 0; i < 1000; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		for i := 0; i < 5; i++ {
+			c.Get([]byte("1"))
+		}
+		c.CleanUp()
+		assert.True(t, len(c.sketch.table) > 1)
+		hot := c.sketch.frequency([]byte("1"))
+		assert.True(t, hot > 1)
+		var buf bytes.Buffer
+		assert.Nil(t, c.SaveFrequencySketch(&buf))
+
+		// the sketch of the new cache has not grown yet
+		restored := NewCacheBuilder().MaximumWeight(1000).Weigher(weigher).Executor(NewCallerRunsExecutor()).Build()
+		assert.Equal(t, 1, len(restored.sketch.table))
+		assert.Nil(t, restored.LoadFrequencySketch(&buf))
+		assert.Equal(t, len(c.sketch.table), len(restored.sketch.table))
+		assert.Equal(t, hot, restored.sketch.frequency([]byte("1")))
+
+		// the restored counters are kept while the entries grow up to the table length
+		for i := 0; i < 1000; i++ {
+			restored.Put([]byte(strconv.Itoa(i)), i)
+		}
+		assert.Equal(t, len(c.sketch.table), len(restored.sketch.table))
+		assert.True(t, restored.sketch.frequency([]byte("1")) >= hot)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		var buf bytes.Buffer
+		assert.Nil(t, c.SaveFrequencySketch(&buf))
+		data := buf.Bytes()
+
+		larger := NewCacheBuilder().MaximumSize(1000).Executor(NewCallerRunsExecutor()).Build()
+		assert.NotNil(t, larger.LoadFrequencySketch(bytes.NewReader(data)))
+		blocked := NewCacheBuilder().MaximumSize(100).SketchLayout(BlockedLayout).Build()
+		assert.NotNil(t, blocked.LoadFrequencySketch(bytes.NewReader(data)))
+
+		other := NewCacheBuilder().MaximumSize(100).Executor(NewCallerRunsExecutor()).Build()
+		assert.NotNil(t, other.LoadFrequencySketch(bytes.NewReader(data[:len(data)-1])))
+		bad := append([]byte{sketchEncodingVersion + 1}, data[1:]...)
+		assert.NotNil(t, other.LoadFrequencySketch(bytes.NewReader(bad)))
+	})
+}
+
+func BenchmarkBoundedLocalCache_workload(b *testing.B) {
+	const items = 1 << 16
+	for _, bm := range []struct {
+		name    string
+		newKeys func(r *rand.Rand) workload.KeyGenerator
+		mix     workload.Mix
+	}{
+		{"zipfian read", func(r *rand.Rand) workload.KeyGenerator { return workload.NewZipfian(r, items, 0.99) }, workload.ReadOnly},
+		{"zipfian read write", func(r *rand.Rand) workload.KeyGenerator { return workload.NewZipfian(r, items, 0.99) }, workload.Mix{Read: 3, Write: 1}},
+		{"uniform read", func(r *rand.Rand) workload.KeyGenerator { return workload.NewUniform(r, items) }, workload.ReadOnly},
+		{"scan read", func(r *rand.Rand) workload.KeyGenerator { return workload.NewLoopingScan(items) }, workload.ReadOnly},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c := NewCacheBuilder().MaximumSize(items / 4).Build()
+			r := rand.New(rand.NewSource(1))
+			w := workload.New(r, bm.newKeys(r), bm.mix, nil)
+			ops := make([]workload.Op, 1<<16)
+			for i := range ops {
+				ops[i] = w.Next()
+			}
+			var start int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// the goroutines replay the ops from the different offsets
+				i := int(atomic.AddInt64(&start, 1<<10))
+				for ; pb.Next(); i++ {
+					op := ops[i&(len(ops)-1)]
+					switch op.Type {
+					case workload.Read:
+						if c.Get(op.Key) == nil {
+							c.Put(op.Key, op.ValueSize)
+						}
+					case workload.Write:
+						c.Put(op.Key, op.ValueSize)
+					case workload.Delete:
+						c.Delete(op.Key)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cocoa
+
+// lruPolicy evicts the least recently used entry. With the accessOrder disabled it is the FIFO policy,
+// which evicts the oldest entry.
+type lruPolicy struct {
+	deque       *AccessOrderDeque
+	accessOrder bool
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{deque: &AccessOrderDeque{}, accessOrder: true}
+}
+
+func newFIFOPolicy() *lruPolicy {
+	return &lruPolicy{deque: &AccessOrderDeque{}}
+}
+
+func (p *lruPolicy) OnAdd(n *Node) {
+	p.deque.PushBack(n)
+}
+
+func (p *lruPolicy) OnAccess(n *Node) {
+	if p.accessOrder && p.deque.Contains(n) {
+		p.deque.MoveToBack(n)
+	}
+}
+
+func (p *lruPolicy) OnUpdate(n *Node, weightDiff int) {}
+
+func (p *lruPolicy) OnRemove(n *Node) {
+	p.deque.Remove(n)
+}
+
+func (p *lruPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() {
+		node := p.deque.GetFront()
+		// stop rather than spin if the evict failed to remove the node
+		if node == nil || node == previous {
+			return
+		}
+		previous = node
+		evict(node)
+	}
+}
+
+// accessOrderDeques returns the deque only if it is in access order.
+func (p *lruPolicy) accessOrderDeques() []*AccessOrderDeque {
+	if !p.accessOrder {
+		return nil
+	}
+	return []*AccessOrderDeque{p.deque}
+}
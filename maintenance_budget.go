@@ -0,0 +1,65 @@
+package cocoa
+
+import (
+	"time"
+)
+
+const (
+	// The maximum number of entries scanned for the expiration per run, by the policies without access order.
+	maxScannedEntries = 4096
+)
+
+// maintenanceBudget bounds the work of a single maintenance run, so the eviction lock is held for a
+// predictable time. When the budget is exhausted the maintenance yields, and the drain status is
+// switched to ProcessingToRequired so the remaining work is rescheduled.
+// Caller must hold the eviction lock.
+type maintenanceBudget struct {
+	// the maximum number of write tasks drained per run
+	maxDrainTasks int
+	// the maximum number of entries evicted or expired per run, zero means unlimited
+	maxEvictions int
+	// the maximum duration of a run, zero means unlimited
+	maxTime time.Duration
+	ticker  Ticker
+
+	evictions int
+	scanned   int
+	deadline  int64
+}
+
+// start resets the budget for a new maintenance run.
+func (b *maintenanceBudget) start() {
+	b.evictions = 0
+	b.scanned = 0
+	if b.maxTime > 0 {
+		b.deadline = b.ticker.Read() + int64(b.maxTime)
+	}
+}
+
+// onEviction records that an entry was evicted or expired.
+func (b *maintenanceBudget) onEviction() {
+	b.evictions++
+}
+
+// timeExceeded returns if the run has used up its time slice.
+func (b *maintenanceBudget) timeExceeded() bool {
+	return b.maxTime > 0 && b.ticker.Read() >= b.deadline
+}
+
+// evictionExhausted returns if the run may not evict any more entries.
+func (b *maintenanceBudget) evictionExhausted() bool {
+	if b.maxEvictions > 0 && b.evictions >= b.maxEvictions {
+		return true
+	}
+	return b.timeExceeded()
+}
+
+// onScan records that the entries were scanned for the expiration.
+func (b *maintenanceBudget) onScan(entries int) {
+	b.scanned += entries
+}
+
+// scanExhausted returns if the run may not scan any more entries.
+func (b *maintenanceBudget) scanExhausted() bool {
+	return b.scanned >= maxScannedEntries || b.timeExceeded()
+}
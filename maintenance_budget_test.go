@@ -0,0 +1,143 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// queuedExecutor queues the functions, which are run by the test.
+type queuedExecutor struct {
+	fns []func()
+}
+
+func (e *queuedExecutor) Execute(fn func()) {
+	e.fns = append(e.fns, fn)
+}
+
+func (e *queuedExecutor) runNext() bool {
+	if len(e.fns) == 0 {
+		return false
+	}
+	fn := e.fns[0]
+	e.fns = e.fns[1:]
+	fn()
+	return true
+}
+
+// orderTask records the order in which the tasks run.
+type orderTask struct {
+	id    int
+	order *[]int
+}
+
+func (t *orderTask) run() {
+	*t.order = append(*t.order, t.id)
+}
+
+func TestMaintenanceBudget(t *testing.T) {
+	t.Run("TestMaxEvictionsPerMaintenance", func(t *testing.T) {
+		e := &queuedExecutor{}
+		c := NewCacheBuilder().
+			MaximumSize(10).
+			Executor(e).
+			MaxEvictionsPerMaintenance(4).
+			Build()
+		for i := 0; i < 20; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		assert.Equal(t, 1, len(e.fns))
+
+		assert.True(t, e.runNext())
+		assert.Equal(t, 16, c.Size())
+		// the maintenance yielded and rescheduled itself
+		assert.Equal(t, 1, len(e.fns))
+		assert.True(t, e.runNext())
+		assert.Equal(t, 12, c.Size())
+		assert.True(t, e.runNext())
+		assert.Equal(t, 10, c.Size())
+		assert.Equal(t, Idle, c.drainState.get())
+		assert.False(t, e.runNext())
+	})
+
+	t.Run("TestMaxDrainTasks", func(t *testing.T) {
+		e := &queuedExecutor{}
+		c := NewCacheBuilder().
+			MaximumSize(100).
+			Executor(e).
+			MaxDrainTasks(5).
+			Build()
+		for i := 0; i < 12; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		assert.True(t, e.runNext())
+		assert.Equal(t, 7, c.writeBuffer.size())
+		assert.True(t, e.runNext())
+		assert.Equal(t, 2, c.writeBuffer.size())
+		assert.True(t, e.runNext())
+		assert.Equal(t, 0, c.writeBuffer.size())
+		assert.Equal(t, 12, c.weightedSize)
+	})
+
+	t.Run("TestTaskRunsAfterPendingWrites", func(t *testing.T) {
+		e := &queuedExecutor{}
+		c := NewCacheBuilder().
+			MaximumSize(100).
+			Executor(e).
+			MaxDrainTasks(2).
+			Build()
+		var order []int
+		for i := 0; i < 5; i++ {
+			assert.True(t, c.writeBuffer.offer(&orderTask{id: i, order: &order}))
+		}
+		// the budget stops the draining, so the task is enqueued behind the remaining writes
+		c.performCleanUp(&orderTask{id: 5, order: &order})
+		assert.Equal(t, []int{0, 1}, order)
+		assert.Equal(t, 4, c.writeBuffer.size())
+		for e.runNext() {
+		}
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, order)
+		assert.Equal(t, 0, c.writeBuffer.size())
+
+		// the task runs at once after a drained write buffer
+		c.performCleanUp(&orderTask{id: 6, order: &order})
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6}, order)
+	})
+
+	t.Run("TestCallerRunsDoesNotReenter", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		c := NewCacheBuilder().
+			MaximumSize(1000).
+			Executor(NewCallerRunsExecutor()).
+			Ticker(ticker).
+			ExpireAfterAccess(time.Minute).
+			MaxEvictionsPerMaintenance(1).
+			Build()
+		for i := 0; i < 100; i++ {
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		c.CleanUp()
+		assert.Equal(t, 100, c.Size())
+
+		ticker.Advance(time.Minute)
+		// a single run within the budget, the remaining work is left to the next operation
+		c.CleanUp()
+		assert.Equal(t, 99, c.Size())
+		assert.Equal(t, DrainState(Required), c.drainState.get())
+		c.Get([]byte("0"))
+		assert.Equal(t, 98, c.Size())
+	})
+
+	t.Run("TestMaxMaintenanceTime", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		b := maintenanceBudget{maxEvictions: 0, maxTime: time.Millisecond, ticker: ticker}
+		b.start()
+		assert.False(t, b.evictionExhausted())
+		ticker.Advance(time.Millisecond)
+		assert.True(t, b.timeExceeded())
+		assert.True(t, b.evictionExhausted())
+		b.start()
+		assert.False(t, b.evictionExhausted())
+	})
+}
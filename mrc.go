@@ -0,0 +1,255 @@
+package cocoa
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// The sampled hashes are in [0, shardsModulus), a key is sampled if its hash is below the threshold.
+	shardsBits    = 24
+	shardsModulus = 1 << shardsBits
+	// The default maximum number of the sampled keys, which bounds the memory of the estimator.
+	defaultMaxCurveSamples = 8192
+	// The reuse distances below are counted exactly, the larger ones in buckets growing by 2%, so a hit rate
+	// is interpolated within a bucket.
+	curveLinearBuckets = 256
+	curveBucketGrowth  = 1.02
+	// The number of the stripes counting the reads.
+	curveReadStripes = 32
+)
+
+// HitRatePoint is the estimated hit rate of an LRU cache of the size.
+type HitRatePoint struct {
+	Size    int
+	HitRate float64
+}
+
+// curveSample is a sampled key, whose last reference is at the time.
+type curveSample struct {
+	key  string
+	hash uint64
+	time int
+}
+
+// hitRateCurve estimates the miss ratio curve by SHARDS, the Spatially Hashed Approximate Reuse Distance
+// Sampling. The keys are sampled by hash at the rate of threshold/shardsModulus, so the reuse distance of a
+// sampled key counts the sampled keys only, and is scaled up by the rate. Once the samples exceed the
+// maximum, the keys of the largest hashes are dropped and the threshold is lowered to keep the memory fixed.
+// The curve is in the number of entries, of the LRU policy, and counts the reads only.
+type hitRateCurve struct {
+	// read atomically on the fast path, only lowered under the lock
+	threshold uint64
+	// the number of all reads, striped by the key hash to avoid the contention
+	readStripes [curveReadStripes]paddedCounter
+
+	mu         sync.Mutex
+	maxSamples int
+	samples    map[string]*curveSample
+	// the samples by hash, the largest first
+	byHash curveSamplesByHash
+	// the Fenwick tree counting a sample at the time of its last reference, so the reuse distance of a
+	// sample is the count after its time
+	tree  []int
+	clock int
+
+	// the scaled sampled reads by the bucket of their scaled reuse distance
+	histogram []float64
+	// the scaled sampled reads, which differs from the number of all reads by the sampling error
+	sampledReads float64
+}
+
+type paddedCounter struct {
+	count int64
+	_     [56]byte
+}
+
+func newHitRateCurve(sampleRate float64, maxSamples int) *hitRateCurve {
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	if maxSamples <= 0 {
+		maxSamples = defaultMaxCurveSamples
+	}
+	threshold := uint64(sampleRate * shardsModulus)
+	if threshold == 0 {
+		threshold = 1
+	}
+	return &hitRateCurve{
+		threshold:  threshold,
+		maxSamples: maxSamples,
+		samples:    make(map[string]*curveSample),
+		tree:       make([]int, 4*maxSamples+1),
+	}
+}
+
+// record records a reference of the key of the hash, a read is counted as a hit or a miss of the curve and
+// a write only makes the key the most recent. The key is sampled by the fixed seeded hash of the sketch rather
+// than the hash of the map, so the sampled keys are the same between runs.
+func (m *hitRateCurve) record(key []byte, keyHash int, read bool) {
+	if read {
+		atomic.AddInt64(&m.readStripes[keyHash&(curveReadStripes-1)].count, 1)
+	}
+	h := spreadCurveHash(sketchHash(key, 0))
+	if h >= atomic.LoadUint64(&m.threshold) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h >= m.threshold {
+		return
+	}
+	rate := float64(m.threshold) / shardsModulus
+	s, ok := m.samples[*bytesToString(key)]
+	if read {
+		m.sampledReads += 1 / rate
+		if ok {
+			distance := float64(m.sum(m.clock-1)-m.sum(s.time)) / rate
+			m.addHistogram(distance, 1/rate)
+		}
+	}
+	if ok {
+		m.add(s.time, -1)
+	} else {
+		s = &curveSample{key: string(key), hash: h}
+		m.samples[s.key] = s
+		heap.Push(&m.byHash, s)
+	}
+	s.time = m.clock
+	m.add(s.time, 1)
+	m.clock++
+	if m.clock == len(m.tree)-1 {
+		m.compact()
+	}
+	if len(m.samples) > m.maxSamples {
+		m.lowerThreshold()
+	}
+}
+
+// lowerThreshold drops the samples of the largest hash, and stops sampling their hash.
+func (m *hitRateCurve) lowerThreshold() {
+	threshold := m.byHash[0].hash
+	for len(m.byHash) > 0 && m.byHash[0].hash >= threshold {
+		s := heap.Pop(&m.byHash).(*curveSample)
+		m.add(s.time, -1)
+		delete(m.samples, s.key)
+	}
+	atomic.StoreUint64(&m.threshold, threshold)
+}
+
+// compact renumbers the times of the samples from 0 in order, once the clock reaches the end of the tree.
+func (m *hitRateCurve) compact() {
+	ordered := make([]*curveSample, 0, len(m.samples))
+	for _, s := range m.samples {
+		ordered = append(ordered, s)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].time < ordered[j].time
+	})
+	for i := range m.tree {
+		m.tree[i] = 0
+	}
+	for i, s := range ordered {
+		s.time = i
+		m.add(i, 1)
+	}
+	m.clock = len(ordered)
+}
+
+// add adds the delta at the time of the Fenwick tree.
+func (m *hitRateCurve) add(time, delta int) {
+	for i := time + 1; i < len(m.tree); i += i & -i {
+		m.tree[i] += delta
+	}
+}
+
+// sum returns the count of the times up to the time inclusive.
+func (m *hitRateCurve) sum(time int) int {
+	total := 0
+	for i := time + 1; i > 0; i -= i & -i {
+		total += m.tree[i]
+	}
+	return total
+}
+
+func (m *hitRateCurve) addHistogram(distance, reads float64) {
+	bucket := int(distance)
+	if distance >= curveLinearBuckets {
+		bucket = curveLinearBuckets + int(math.Log(distance/curveLinearBuckets)/math.Log(curveBucketGrowth))
+	}
+	for len(m.histogram) <= bucket {
+		m.histogram = append(m.histogram, 0)
+	}
+	m.histogram[bucket] += reads
+}
+
+// bucketBounds returns the range of the distances of the bucket.
+func bucketBounds(bucket int) (low, high float64) {
+	if bucket < curveLinearBuckets {
+		return float64(bucket), float64(bucket + 1)
+	}
+	exponent := float64(bucket - curveLinearBuckets)
+	return curveLinearBuckets * math.Pow(curveBucketGrowth, exponent),
+		curveLinearBuckets * math.Pow(curveBucketGrowth, exponent+1)
+}
+
+// hitRates returns the estimated hit rates of the sizes. A read hits a cache of the size if its reuse
+// distance is less than the size. As the SHARDS adjustment, the difference of the sampled reads from all
+// reads, mostly caused by sampling or missing a hot key, is taken as the hits of the smallest distance.
+func (m *hitRateCurve) hitRates(sizes []int) []HitRatePoint {
+	var reads int64
+	for i := range m.readStripes {
+		reads += atomic.LoadInt64(&m.readStripes[i].count)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	points := make([]HitRatePoint, len(sizes))
+	for i, size := range sizes {
+		points[i].Size = size
+		if reads == 0 || size <= 0 {
+			continue
+		}
+		hits := float64(reads) - m.sampledReads
+		for bucket, count := range m.histogram {
+			low, high := bucketBounds(bucket)
+			if high > float64(size) {
+				if low < float64(size) {
+					hits += count * (float64(size) - low) / (high - low)
+				}
+				break
+			}
+			hits += count
+		}
+		points[i].HitRate = math.Max(0, math.Min(1, hits/float64(reads)))
+	}
+	return points
+}
+
+// spreadCurveHash spreads the hash of the key into [0, shardsModulus).
+func spreadCurveHash(h uint64) uint64 {
+	x := h * 0x9e3779b97f4a7c15
+	return x >> (64 - shardsBits)
+}
+
+// curveSamplesByHash is a max-heap of the samples by hash.
+type curveSamplesByHash []*curveSample
+
+func (h curveSamplesByHash) Len() int { return len(h) }
+
+func (h curveSamplesByHash) Less(i, j int) bool { return h[i].hash > h[j].hash }
+
+func (h curveSamplesByHash) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *curveSamplesByHash) Push(x interface{}) {
+	*h = append(*h, x.(*curveSample))
+}
+
+func (h *curveSamplesByHash) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,140 @@
+package cocoa
+
+import (
+	"container/list"
+	"github.com/louyuting/cocoa/workload"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// lruHitRateForTest replays the keys through an exact LRU of the size.
+func lruHitRateForTest(keys [][]byte, size int) float64 {
+	order := list.New()
+	index := make(map[string]*list.Element)
+	hits := 0
+	for _, key := range keys {
+		if e, ok := index[string(key)]; ok {
+			hits++
+			order.MoveToFront(e)
+			continue
+		}
+		index[string(key)] = order.PushFront(string(key))
+		if order.Len() > size {
+			delete(index, order.Remove(order.Back()).(string))
+		}
+	}
+	return float64(hits) / float64(len(keys))
+}
+
+func TestHitRateCurve(t *testing.T) {
+	t.Run("exact without sampling", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(1000).TrackHitRateCurve(1, 0).Build()
+		for i := 0; i < 1000; i++ {
+			c.Get([]byte(strconv.Itoa(i % 100)))
+		}
+		points := c.HitRateCurve([]int{50, 200})
+		assert.Equal(t, 50, points[0].Size)
+		assert.Equal(t, 0.0, points[0].HitRate)
+		assert.InDelta(t, 0.9, points[1].HitRate, 1e-9)
+	})
+
+	t.Run("writes are not counted", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(10).TrackHitRateCurve(1, 0).Build()
+		c.Put([]byte("a"), 1)
+		c.Get([]byte("a"))
+		c.Get([]byte("b"))
+		points := c.HitRateCurve([]int{10})
+		assert.Equal(t, 0.5, points[0].HitRate)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, NewBoundedLocalCache(10).HitRateCurve([]int{10}))
+	})
+
+	t.Run("sampled estimate is close to LRU", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		w := workload.New(r, workload.NewScrambledZipfian(r, 100000, 0.99), workload.ReadOnly, nil)
+		keys := make([][]byte, 500000)
+		for i := range keys {
+			keys[i] = w.Next().Key
+		}
+		// the samples exceed the maximum, which lowers the rate
+		m := newHitRateCurve(0.1, 2048)
+		data := newSegmentHashMap()
+		for _, key := range keys {
+			m.record(key, data.hash(key), true)
+		}
+		assert.Equal(t, 2048, len(m.samples))
+		assert.True(t, m.threshold < shardsModulus/10)
+
+		// the sizes below 1/rate are estimated poorly
+		sizes := []int{1000, 10000}
+		for i, point := range m.hitRates(sizes) {
+			assert.InDelta(t, lruHitRateForTest(keys, sizes[i]), point.HitRate, 0.05, strconv.Itoa(sizes[i]))
+		}
+	})
+
+	t.Run("sampled keys are the same between runs", func(t *testing.T) {
+		sample := func() map[string]*curveSample {
+			m := newHitRateCurve(0.1, 64)
+			data := newSegmentHashMap()
+			for i := 0; i < 10000; i++ {
+				key := []byte(strconv.Itoa(i))
+				m.record(key, data.hash(key), true)
+			}
+			return m.samples
+		}
+		first := sample()
+
+		// another run seeds the hash of the map differently
+		seeded := hashkey
+		defer func() { hashkey = seeded }()
+		for i := range hashkey {
+			hashkey[i] += 2
+		}
+		second := sample()
+		assert.Equal(t, len(first), len(second))
+		for key := range first {
+			assert.Contains(t, second, key)
+		}
+	})
+
+	t.Run("compaction keeps the distances", func(t *testing.T) {
+		m := newHitRateCurve(1, 4)
+		data := newSegmentHashMap()
+		for i := 0; i < 100; i++ {
+			key := []byte(strconv.Itoa(i % 3))
+			m.record(key, data.hash(key), true)
+		}
+		assert.True(t, m.clock < len(m.tree))
+		points := m.hitRates([]int{2, 3})
+		assert.Equal(t, 0.0, points[0].HitRate)
+		assert.InDelta(t, 0.97, points[1].HitRate, 1e-9)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		c := NewCacheBuilder().MaximumSize(1000).TrackHitRateCurve(0.5, 256).Build()
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 10000; i++ {
+					key := []byte(strconv.Itoa((i * (g + 1)) % 2000))
+					if c.Get(key) == nil {
+						c.Put(key, i)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		points := c.HitRateCurve([]int{10, 100, 1000, 10000})
+		for i := 1; i < len(points); i++ {
+			assert.True(t, points[i].HitRate >= points[i-1].HitRate)
+		}
+		assert.True(t, points[3].HitRate > 0)
+	})
+}
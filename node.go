@@ -1,5 +1,10 @@
 package cocoa
 
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
 type QueueType int32
 
 const (
@@ -8,26 +13,162 @@ const (
 	Protected
 )
 
+// NodeState is the lifecycle state of a node.
+// The state transitions must be made under the segment lock that owns the node's key,
+// and the state may be read without lock.
+type NodeState int32
+
+const (
+	// Alive means the node is in both the hash map and the page replacement policy.
+	Alive NodeState = iota
+	// Retired means the node was removed from the hash map, but is still in the page replacement policy.
+	Retired
+	// Dead means the node was removed from both the hash map and the page replacement policy.
+	Dead
+)
+
 type Node struct {
-	Key        []byte
-	Value      interface{}
-	weight     int
+	Key   []byte
+	Value interface{}
+	// the weight counted by the policy, guarded by the eviction lock
+	weight int
+	// the weight of the value, guarded by the segment lock
+	valueWeight int
+	// the links of the deques, which are allocated with the node only if the policy keeps it in a deque
+	links *nodeLinks
+	// the ticker time in nanoseconds of the last access and the last write
+	accessTime int64
+	writeTime  int64
+	state      NodeState
+	// if the weight is counted in the weighted size of the cache, guarded by the eviction lock
+	accounted bool
+	// the 2-bit access frequency of the S3-FIFO policy, guarded by the eviction lock
+	frequency uint8
+	// the QueueType of the deque the node is in, a byte so that it fits in the word of the state
+	dequeIn uint8
+}
+
+// nodeLinks are the links of a node in an AccessOrderDeque.
+type nodeLinks struct {
 	prev, next *Node
-	dequeIn    QueueType
+}
+
+// linkedNode allocates a node and its links at once.
+type linkedNode struct {
+	node  Node
+	links nodeLinks
+}
+
+// newNode returns the node of the entry, with its links if linked.
+func newNode(key []byte, value interface{}, weight int, now int64, linked bool) *Node {
+	var n *Node
+	if linked {
+		l := &linkedNode{}
+		n = &l.node
+		n.links = &l.links
+	} else {
+		n = &Node{}
+	}
+	n.Key = key
+	n.Value = value
+	n.weight = weight
+	n.valueWeight = weight
+	n.accessTime = now
+	n.writeTime = now
+	return n
+}
+
+// link returns the links of the node, which are allocated if the node was not linked.
+func (n *Node) link() *nodeLinks {
+	if n.links == nil {
+		n.links = &nodeLinks{}
+	}
+	return n.links
+}
+
+func (n *Node) prevNode() *Node {
+	if n.links == nil {
+		return nil
+	}
+	return n.links.prev
+}
+
+func (n *Node) nextNode() *Node {
+	if n.links == nil {
+		return nil
+	}
+	return n.links.next
 }
 
 func (n *Node) makeIn(newQueueType QueueType) {
-	n.dequeIn = newQueueType
+	n.dequeIn = uint8(newQueueType)
+}
+
+func (n *Node) queueType() QueueType {
+	return QueueType(n.dequeIn)
 }
 
 func (n *Node) inWindow() bool {
-	return n.dequeIn == Window
+	return n.queueType() == Window
 }
 
 func (n *Node) inMainProbation() bool {
-	return n.dequeIn == Probation
+	return n.queueType() == Probation
 }
 
 func (n *Node) inMainProtected() bool {
-	return n.dequeIn == Protected
+	return n.queueType() == Protected
+}
+
+func (n *Node) getState() NodeState {
+	statePtr := (*int32)(unsafe.Pointer(&n.state))
+	return NodeState(atomic.LoadInt32(statePtr))
+}
+
+func (n *Node) setState(state NodeState) {
+	statePtr := (*int32)(unsafe.Pointer(&n.state))
+	atomic.StoreInt32(statePtr, int32(state))
+}
+
+// isAlive returns if the entry is in both the hash map and the page replacement policy.
+func (n *Node) isAlive() bool {
+	return n.getState() == Alive
+}
+
+// isRetired returns if the entry was removed from the hash map but not yet from the page replacement policy.
+func (n *Node) isRetired() bool {
+	return n.getState() == Retired
+}
+
+// isDead returns if the entry was removed from both the hash map and the page replacement policy.
+func (n *Node) isDead() bool {
+	return n.getState() == Dead
+}
+
+// retire sets the node to the retired state.
+// Caller must hold the segment lock.
+func (n *Node) retire() {
+	n.setState(Retired)
+}
+
+// die sets the node to the dead state.
+// Caller must hold the segment lock.
+func (n *Node) die() {
+	n.setState(Dead)
+}
+
+func (n *Node) getAccessTime() int64 {
+	return atomic.LoadInt64(&n.accessTime)
+}
+
+func (n *Node) setAccessTime(nanos int64) {
+	atomic.StoreInt64(&n.accessTime, nanos)
+}
+
+func (n *Node) getWriteTime() int64 {
+	return atomic.LoadInt64(&n.writeTime)
+}
+
+func (n *Node) setWriteTime(nanos int64) {
+	atomic.StoreInt64(&n.writeTime, nanos)
 }
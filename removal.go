@@ -0,0 +1,35 @@
+package cocoa
+
+// RemovalCause is the reason why an entry was removed from the cache.
+type RemovalCause int32
+
+const (
+	// Explicit means the entry was removed by Delete.
+	Explicit RemovalCause = iota
+	// Replaced means the entry's value was replaced by Put.
+	Replaced
+	// Evicted means the entry was evicted due to the maximum size.
+	Evicted
+	// Expired means the entry was not accessed within the expiration duration.
+	Expired
+)
+
+func (c RemovalCause) String() string {
+	switch c {
+	case Explicit:
+		return "Explicit"
+	case Replaced:
+		return "Replaced"
+	case Evicted:
+		return "Evicted"
+	case Expired:
+		return "Expired"
+	default:
+		return "Unknown"
+	}
+}
+
+// RemovalListener is notified when an entry is removed from the cache.
+// The listener is run by the cache's Executor, so it must be safe for concurrent use
+// unless the Executor runs the notifications one by one.
+type RemovalListener func(key []byte, value interface{}, cause RemovalCause)
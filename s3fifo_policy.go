@@ -0,0 +1,167 @@
+package cocoa
+
+const (
+	// The maximum of the 2-bit frequency of a node.
+	s3FIFOMaxFrequency = 3
+	// The small queue reuses the Window type of the node, the main queue reuses the Protected type.
+	s3FIFOSmall = Window
+	s3FIFOMain  = Protected
+)
+
+// s3FIFOPolicy is the S3-FIFO policy. New entries are added to the small FIFO queue, which holds 10% of the
+// maximum. An entry accessed more than once while in the small queue is moved to the main FIFO queue,
+// otherwise it is evicted and its key hash is kept in the ghost queue. A new entry whose key hash is in the
+// ghost queue is added to the main queue directly. The main queue reinserts the accessed entries with their
+// frequency decremented, i.e. it is a CLOCK. An access only increments the 2-bit frequency of the node,
+// without relinking it.
+type s3FIFOPolicy struct {
+	small *AccessOrderDeque
+	main  *AccessOrderDeque
+	ghost *ghostQueue
+
+	smallWeightedSize int
+	smallMaximum      int
+}
+
+func newS3FIFOPolicy(maximum int) *s3FIFOPolicy {
+	smallMaximum := maximum / 10
+	if smallMaximum < 1 {
+		smallMaximum = 1
+	}
+	return &s3FIFOPolicy{
+		small:        &AccessOrderDeque{},
+		main:         &AccessOrderDeque{},
+		ghost:        newGhostQueue(maximum - smallMaximum),
+		smallMaximum: smallMaximum,
+	}
+}
+
+func (p *s3FIFOPolicy) OnAdd(n *Node) {
+	n.frequency = 0
+	if p.ghost.remove(uint64(hash(n.Key))) {
+		n.makeIn(s3FIFOMain)
+		p.main.PushBack(n)
+		return
+	}
+	n.makeIn(s3FIFOSmall)
+	p.small.PushBack(n)
+	p.smallWeightedSize += n.weight
+}
+
+func (p *s3FIFOPolicy) OnAccess(n *Node) {
+	if n.frequency < s3FIFOMaxFrequency {
+		n.frequency++
+	}
+}
+
+func (p *s3FIFOPolicy) OnUpdate(n *Node, weightDiff int) {
+	if n.queueType() == s3FIFOSmall && p.small.Contains(n) {
+		p.smallWeightedSize += weightDiff
+	}
+}
+
+func (p *s3FIFOPolicy) OnRemove(n *Node) {
+	if n.queueType() == s3FIFOSmall {
+		if p.small.Contains(n) {
+			p.small.Remove(n)
+			p.smallWeightedSize -= n.weight
+		}
+	} else {
+		p.main.Remove(n)
+	}
+}
+
+func (p *s3FIFOPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() {
+		var victim *Node
+		if p.smallWeightedSize > p.smallMaximum || p.main.IsEmpty() {
+			victim = p.smallVictim()
+		}
+		if victim == nil {
+			victim = p.mainVictim()
+		}
+		// stop rather than spin if the evict failed to remove the node
+		if victim == nil || victim == previous {
+			return
+		}
+		previous = victim
+		if victim.queueType() == s3FIFOSmall {
+			p.ghost.add(uint64(hash(victim.Key)))
+		}
+		evict(victim)
+	}
+}
+
+// smallVictim moves the entries accessed more than once to the main queue, until an entry to evict is found.
+// return nil if the small queue is emptied
+func (p *s3FIFOPolicy) smallVictim() *Node {
+	for node := p.small.GetFront(); node != nil; node = p.small.GetFront() {
+		if node.frequency > 1 {
+			p.small.Remove(node)
+			p.smallWeightedSize -= node.weight
+			node.frequency = 0
+			node.makeIn(s3FIFOMain)
+			p.main.PushBack(node)
+			continue
+		}
+		return node
+	}
+	return nil
+}
+
+// mainVictim reinserts the accessed entries with the frequency decremented, until an entry to evict is found.
+// return nil if the main queue is empty
+func (p *s3FIFOPolicy) mainVictim() *Node {
+	for node := p.main.GetFront(); node != nil; node = p.main.GetFront() {
+		if node.frequency > 0 {
+			node.frequency--
+			p.main.MoveToBack(node)
+			continue
+		}
+		return node
+	}
+	return nil
+}
+
+// ghostQueue is a FIFO queue of the key hashes of the recently evicted entries, bounded by the capacity.
+type ghostQueue struct {
+	hashes []uint64
+	// the number of hashes ever added, the next position is count % capacity
+	count uint64
+	// the count when the hash was added, so that an older duplicate does not remove the newer one
+	index map[uint64]uint64
+}
+
+func newGhostQueue(capacity int) *ghostQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ghostQueue{
+		hashes: make([]uint64, capacity),
+		index:  make(map[uint64]uint64, capacity),
+	}
+}
+
+func (g *ghostQueue) add(hash uint64) {
+	pos := g.count % uint64(len(g.hashes))
+	if g.count >= uint64(len(g.hashes)) {
+		// drop the oldest hash
+		oldest := g.hashes[pos]
+		if added, ok := g.index[oldest]; ok && added == g.count-uint64(len(g.hashes)) {
+			delete(g.index, oldest)
+		}
+	}
+	g.hashes[pos] = hash
+	g.index[hash] = g.count
+	g.count++
+}
+
+// remove removes the hash, and returns if it was in the queue.
+func (g *ghostQueue) remove(hash uint64) bool {
+	if _, ok := g.index[hash]; !ok {
+		return false
+	}
+	delete(g.index, hash)
+	return true
+}
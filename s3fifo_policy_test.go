@@ -0,0 +1,73 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestS3FIFOPolicy(t *testing.T) {
+	t.Run("small queue filters one-hit entries", func(t *testing.T) {
+		c := policyCacheForTest(S3FIFOPolicy, 20)
+		p := c.policy.(*s3FIFOPolicy)
+		hot := putNodeForTest(c, "hot")
+		c.onAccess(hot)
+		c.onAccess(hot)
+		for i := 0; i < 30; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+			c.evictEntries()
+		}
+		assert.True(t, hot.isAlive())
+		assert.Equal(t, s3FIFOMain, hot.queueType())
+		assert.Equal(t, 20, c.weightedSize)
+		assert.True(t, p.main.GetFront() == hot && p.main.GetBack() == hot)
+		assert.Equal(t, 19, p.smallWeightedSize)
+	})
+
+	t.Run("ghost hit is added to main", func(t *testing.T) {
+		c := policyCacheForTest(S3FIFOPolicy, 10)
+		n := putNodeForTest(c, "k")
+		for i := 0; i < 10; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+			c.evictEntries()
+		}
+		assert.True(t, n.isDead())
+
+		n = putNodeForTest(c, "k")
+		assert.Equal(t, s3FIFOMain, n.queueType())
+		assert.False(t, c.policy.(*s3FIFOPolicy).ghost.remove(uint64(hash(n.Key))))
+	})
+
+	t.Run("main queue reinserts accessed entries", func(t *testing.T) {
+		c := policyCacheForTest(S3FIFOPolicy, 10)
+		p := c.policy.(*s3FIFOPolicy)
+		var main []*Node
+		for i := 0; i < 10; i++ {
+			n := putNodeForTest(c, strconv.Itoa(i))
+			p.small.Remove(n)
+			p.smallWeightedSize -= n.weight
+			n.makeIn(s3FIFOMain)
+			p.main.PushBack(n)
+			main = append(main, n)
+		}
+		c.onAccess(main[0])
+		putNodeForTest(c, "new")
+		c.evictEntry(p.mainVictim(), Evicted)
+		assert.True(t, main[0].isAlive())
+		assert.Equal(t, uint8(0), main[0].frequency)
+		assert.True(t, main[1].isDead())
+	})
+
+	t.Run("ghost queue is bounded", func(t *testing.T) {
+		g := newGhostQueue(2)
+		g.add(1)
+		g.add(2)
+		g.add(1)
+		// the oldest 1 is dropped, the newer one is kept
+		g.add(3)
+		assert.True(t, g.remove(1))
+		assert.False(t, g.remove(2))
+		assert.True(t, g.remove(3))
+		assert.Equal(t, 0, len(g.index))
+	})
+}
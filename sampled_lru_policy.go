@@ -0,0 +1,120 @@
+package cocoa
+
+import (
+	"math/rand"
+)
+
+const (
+	// The default number of entries sampled for an eviction.
+	defaultEvictionSamples = 5
+	// The node is resident in the sampled LRU policy once added, the new nodes are in the Window.
+	sampledResident = Protected
+	// The maximum number of the entries skipped in a segment for a sample.
+	maxSampleSkip = 8
+)
+
+// sampledLRUPolicy is the approximate LRU, which keeps no deque and evicts the least recently accessed of
+// the entries sampled from random segments of the map, by the access time of the node. The access and
+// the write buffers are still drained, but the nodes are never relinked, so they are allocated without the
+// links.
+type sampledLRUPolicy struct {
+	data    *SegmentHashMap
+	samples int
+	// the reused buffer of the sampled entries
+	sampled []*Node
+}
+
+func newSampledLRUPolicy(data *SegmentHashMap, samples int) *sampledLRUPolicy {
+	if samples <= 0 {
+		samples = defaultEvictionSamples
+	}
+	return &sampledLRUPolicy{
+		data:    data,
+		samples: samples,
+		sampled: make([]*Node, 0, samples),
+	}
+}
+
+// OnAdd makes the node eligible for the eviction, so that a node is not evicted before its weight is added.
+func (p *sampledLRUPolicy) OnAdd(n *Node) {
+	n.makeIn(sampledResident)
+}
+
+func (p *sampledLRUPolicy) OnAccess(n *Node) {}
+
+func (p *sampledLRUPolicy) OnUpdate(n *Node, weightDiff int) {}
+
+func (p *sampledLRUPolicy) OnRemove(n *Node) {}
+
+func (p *sampledLRUPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	var previous *Node
+	for overweight() {
+		victim := p.sample()
+		if victim == nil {
+			// The pending operations will adjust the size to reflect the correct weight
+			return
+		}
+		// stop rather than spin if the evict failed to remove the node
+		if victim == previous {
+			return
+		}
+		previous = victim
+		evict(victim)
+	}
+}
+
+func (p *sampledLRUPolicy) unlinkedNodes() {}
+
+// sample returns the least recently accessed of the resident entries sampled one per random segment, the
+// following segments are tried if a segment has none.
+func (p *sampledLRUPolicy) sample() *Node {
+	p.sampled = p.sampled[:0]
+	for i := 0; i < p.samples; i++ {
+		start := rand.Intn(len(p.data.table))
+		for j := 0; j < len(p.data.table); j++ {
+			if node := sampleSegment(p.data.table[(start+j)&p.data.mask]); node != nil {
+				p.sampled = append(p.sampled, node)
+				break
+			}
+		}
+		if len(p.sampled) == 0 {
+			// no segment has a resident entry
+			return nil
+		}
+	}
+
+	var victim *Node
+	for _, node := range p.sampled {
+		if victim == nil || node.getAccessTime() < victim.getAccessTime() {
+			victim = node
+		}
+	}
+	return victim
+}
+
+// sampleSegment returns a resident entry of the segment, or nil if there is none. The entry is at a random
+// skip from the random start of the map iteration, which is otherwise biased to the entries after the empty
+// slots of a small map.
+func sampleSegment(seg *Segment) *Node {
+	seg.mux.RLock()
+	defer seg.mux.RUnlock()
+	skip := len(seg.data)
+	if skip > maxSampleSkip {
+		skip = maxSampleSkip
+	}
+	if skip > 0 {
+		skip = rand.Intn(skip)
+	}
+	var sampled *Node
+	for _, node := range seg.data {
+		if node.queueType() != sampledResident {
+			continue
+		}
+		sampled = node
+		if skip == 0 {
+			break
+		}
+		skip--
+	}
+	return sampled
+}
@@ -0,0 +1,116 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestSampledLRUPolicy(t *testing.T) {
+	t.Run("evicts the least recently accessed sample", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		// sampling far more than the entries is nearly the exact LRU
+		c := NewCacheBuilder().MaximumSize(100).EvictionPolicy(SampledLRUPolicy).EvictionSamples(1000).
+			Ticker(ticker).Executor(NewCallerRunsExecutor()).Build()
+		for i := 0; i < 100; i++ {
+			ticker.Advance(time.Second)
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		for i := 0; i < 10; i++ {
+			ticker.Advance(time.Second)
+			c.Get([]byte(strconv.Itoa(i)))
+		}
+		for i := 100; i < 110; i++ {
+			ticker.Advance(time.Second)
+			c.Put([]byte(strconv.Itoa(i)), i)
+		}
+		c.performCleanUp(nil)
+		assert.Equal(t, 100, c.Size())
+		assert.Equal(t, 100, c.weightedSize)
+		for i := 0; i < 10; i++ {
+			assert.True(t, c.Contains([]byte(strconv.Itoa(i))))
+		}
+		for i := 10; i < 20; i++ {
+			assert.False(t, c.Contains([]byte(strconv.Itoa(i))))
+		}
+	})
+
+	t.Run("pending entries are not sampled", func(t *testing.T) {
+		c := policyCacheForTest(SampledLRUPolicy, 1)
+		n := &Node{Key: []byte("pending"), weight: 1}
+		seg := c.data.getSegment(c.data.hash(n.Key))
+		seg.mux.Lock()
+		seg.data["pending"] = n
+		seg.mux.Unlock()
+		assert.Nil(t, c.policy.(*sampledLRUPolicy).sample())
+
+		(&AddTask{c: c, node: n}).run()
+		assert.True(t, c.policy.(*sampledLRUPolicy).sample() == n)
+	})
+
+	t.Run("samples are spread across segments", func(t *testing.T) {
+		c := policyCacheForTest(SampledLRUPolicy, 10000)
+		for i := 0; i < 1000; i++ {
+			putNodeForTest(c, strconv.Itoa(i))
+		}
+		p := c.policy.(*sampledLRUPolicy)
+		p.sample()
+		segments := make(map[*Segment]bool)
+		for _, n := range p.sampled {
+			segments[c.data.getSegment(c.data.hash(n.Key))] = true
+		}
+		assert.Equal(t, defaultEvictionSamples, len(p.sampled))
+		assert.True(t, len(segments) > 1)
+	})
+
+	t.Run("nodes are allocated without the links", func(t *testing.T) {
+		c := policyCacheForTest(SampledLRUPolicy, 10)
+		c.Put([]byte("a"), 1)
+		n, _ := c.data.Get([]byte("a"))
+		assert.Nil(t, n.links)
+
+		c = policyCacheForTest(WTinyLFUPolicy, 10)
+		c.Put([]byte("a"), 1)
+		n, _ = c.data.Get([]byte("a"))
+		assert.NotNil(t, n.links)
+		assert.True(t, unsafe.Sizeof(Node{}) < unsafe.Sizeof(linkedNode{}))
+	})
+}
+
+// memoryPerEntryForTest returns the heap bytes per entry of a cache of the policy filled with the keys.
+func memoryPerEntryForTest(policy PolicyType, keys [][]byte) float64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	c := policyCacheForTest(policy, len(keys))
+	for j, key := range keys {
+		c.Put(key, j)
+	}
+	c.performCleanUp(nil)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(c)
+	return float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)) / float64(len(keys))
+}
+
+func BenchmarkMemoryPerEntry(b *testing.B) {
+	const entries = 1 << 16
+	keys := make([][]byte, entries)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	var linked, lean float64
+	for i := 0; i < b.N; i++ {
+		linked = memoryPerEntryForTest(WTinyLFUPolicy, keys)
+		lean = memoryPerEntryForTest(SampledLRUPolicy, keys)
+	}
+	b.ReportMetric(linked, "B/entry-W-TinyLFU")
+	b.ReportMetric(lean, "B/entry-Sampled-LRU")
+	// the nodes of the Sampled-LRU are allocated without the links
+	if saving := linked - lean; saving < float64(unsafe.Sizeof(nodeLinks{})) {
+		b.Fatalf("the Sampled-LRU saves %.1f B/entry, less than the links", saving)
+	}
+}
@@ -0,0 +1,69 @@
+package cocoa
+
+import (
+	"time"
+)
+
+const (
+	// The tolerance of the pacer, a pending task that fires within it of the new deadline is kept.
+	pacerTolerance = int64(time.Second)
+)
+
+// Scheduler runs a function after a delay, it is used to perform the maintenance when the cache is idle,
+// so the expired entries are removed and notified promptly.
+// The function is cheap and non-blocking, it only schedules the maintenance on the cache's Executor.
+type Scheduler interface {
+	// Schedule runs fn once after the delay, the returned function cancels it.
+	Schedule(delay time.Duration, fn func()) (cancel func())
+}
+
+// systemScheduler schedules by the timers of the go runtime.
+type systemScheduler struct{}
+
+// SystemScheduler returns the Scheduler based on time.AfterFunc.
+func SystemScheduler() Scheduler {
+	return systemScheduler{}
+}
+
+func (s systemScheduler) Schedule(delay time.Duration, fn func()) (cancel func()) {
+	timer := time.AfterFunc(delay, fn)
+	return func() {
+		timer.Stop()
+	}
+}
+
+// pacer arms at most one scheduled task at a time, and re-arms it only when the new deadline
+// is earlier than the pending one beyond the tolerance.
+// Caller must hold the eviction lock.
+type pacer struct {
+	scheduler Scheduler
+	// the ticker time of the pending task
+	nextFireTime int64
+	cancel       func()
+}
+
+func newPacer(scheduler Scheduler) *pacer {
+	return &pacer{scheduler: scheduler}
+}
+
+// schedule arms the task to run after the delay from now.
+func (p *pacer) schedule(now int64, delay time.Duration, fn func()) {
+	scheduleAt := now + int64(delay)
+	if p.cancel != nil {
+		if p.nextFireTime > now && p.nextFireTime-scheduleAt <= pacerTolerance {
+			// the pending task fires soon enough
+			return
+		}
+		p.cancel()
+	}
+	p.nextFireTime = scheduleAt
+	p.cancel = p.scheduler.Schedule(delay, fn)
+}
+
+// stop cancels the pending task if any.
+func (p *pacer) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
@@ -0,0 +1,135 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// manualScheduler records the scheduled functions, which are run by the test.
+type manualScheduler struct {
+	delays    []time.Duration
+	fns       []func()
+	cancelled int
+}
+
+func (s *manualScheduler) Schedule(delay time.Duration, fn func()) (cancel func()) {
+	s.delays = append(s.delays, delay)
+	s.fns = append(s.fns, fn)
+	return func() {
+		s.cancelled++
+	}
+}
+
+func (s *manualScheduler) runLast() {
+	s.fns[len(s.fns)-1]()
+}
+
+func TestSystemScheduler(t *testing.T) {
+	t.Run("TestSchedule", func(t *testing.T) {
+		done := make(chan struct{})
+		SystemScheduler().Schedule(time.Millisecond, func() {
+			close(done)
+		})
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the scheduled function did not run")
+		}
+	})
+
+	t.Run("TestCancel", func(t *testing.T) {
+		ran := make(chan struct{}, 1)
+		cancel := SystemScheduler().Schedule(20*time.Millisecond, func() {
+			ran <- struct{}{}
+		})
+		cancel()
+		select {
+		case <-ran:
+			t.Fatal("the cancelled function ran")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestPacer(t *testing.T) {
+	t.Run("TestKeepPendingWithinTolerance", func(t *testing.T) {
+		s := &manualScheduler{}
+		p := newPacer(s)
+		p.schedule(0, time.Minute, func() {})
+		p.schedule(0, time.Minute-time.Millisecond, func() {})
+		assert.Equal(t, 1, len(s.fns))
+
+		p.schedule(0, time.Second, func() {})
+		assert.Equal(t, 2, len(s.fns))
+		assert.Equal(t, 1, s.cancelled)
+		assert.Equal(t, int64(time.Second), p.nextFireTime)
+
+		p.stop()
+		assert.Equal(t, 2, s.cancelled)
+	})
+}
+
+func TestBoundedLocalCache_expireAfterAccess(t *testing.T) {
+	newCache := func(ticker *FakeTicker, s Scheduler, listener RemovalListener) *BoundedLocalCache {
+		return NewCacheBuilder().
+			MaximumSize(100).
+			Executor(NewCallerRunsExecutor()).
+			Ticker(ticker).
+			ExpireAfterAccess(time.Minute).
+			Scheduler(s).
+			RemovalListener(listener).
+			Build()
+	}
+
+	t.Run("TestExpiredEntryNotVisible", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		c := newCache(ticker, &manualScheduler{}, nil)
+		c.Put([]byte("k1"), 1)
+		ticker.Advance(30 * time.Second)
+		assert.Equal(t, 1, c.Get([]byte("k1")))
+		ticker.Advance(30 * time.Second)
+		assert.Equal(t, 1, c.Get([]byte("k1")))
+		ticker.Advance(time.Minute)
+		assert.Nil(t, c.Get([]byte("k1")))
+	})
+
+	t.Run("TestSchedulerExpiresIdleCache", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		s := &manualScheduler{}
+		removals := make([]removal, 0)
+		c := newCache(ticker, s, func(key []byte, value interface{}, cause RemovalCause) {
+			removals = append(removals, removal{key: string(key), value: value, cause: cause})
+		})
+		c.Put([]byte("k1"), 1)
+		ticker.Advance(10 * time.Second)
+		c.Put([]byte("k2"), 2)
+		assert.Equal(t, time.Minute, s.delays[len(s.delays)-1])
+
+		// no traffic, the scheduled maintenance expires k1 and re-arms for k2
+		ticker.Advance(50 * time.Second)
+		s.runLast()
+		assert.Equal(t, []removal{{key: "k1", value: 1, cause: Expired}}, removals)
+		assert.False(t, c.Contains([]byte("k1")))
+		assert.True(t, c.Contains([]byte("k2")))
+		assert.Equal(t, 10*time.Second, s.delays[len(s.delays)-1])
+
+		ticker.Advance(10 * time.Second)
+		s.runLast()
+		assert.Equal(t, 2, len(removals))
+		assert.Equal(t, 0, c.Size())
+	})
+
+	t.Run("TestCleanupInterval", func(t *testing.T) {
+		s := &manualScheduler{}
+		c := NewCacheBuilder().
+			MaximumSize(100).
+			Executor(NewCallerRunsExecutor()).
+			Ticker(NewFakeTicker()).
+			Scheduler(s).
+			CleanupInterval(time.Second).
+			Build()
+		c.Put([]byte("k1"), 1)
+		assert.Equal(t, []time.Duration{time.Second}, s.delays)
+	})
+}
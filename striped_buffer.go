@@ -0,0 +1,141 @@
+package cocoa
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// The number of attempts to find a stripe before giving up on recording the element.
+	stripedBufferAttempts = 3
+)
+
+var (
+	// The maximum number of stripes of a striped buffer.
+	MaxStripes = ceilingPowerOfTwo(runtime.NumCPU())
+)
+
+// probePool holds the probes used to select a stripe. sync.Pool caches the probes per P,
+// so a probe is roughly the P-local hash in place of the thread-local probe of Java.
+var probePool = sync.Pool{
+	New: func() interface{} {
+		seed := atomic.AddUint32(&probeSeed, 0x9e3779b9)
+		if seed == 0 {
+			seed = 1
+		}
+		return &seed
+	},
+}
+
+var probeSeed uint32
+
+// advanceProbe pseudo-randomly advances the probe by xorshift.
+func advanceProbe(probe uint32) uint32 {
+	probe ^= probe << 13
+	probe ^= probe >> 17
+	probe ^= probe << 5
+	return probe
+}
+
+// stripedBuffer is a striped, non-blocking, lossy read buffer. It starts with a single stripe, and
+// adds stripes when the producers contend for a stripe, up to MaxStripes. Each stripe is a readBuffer
+// padded against false sharing.
+// The multi producers offer concurrently, and the single consumer drains all the stripes.
+type stripedBuffer struct {
+	// The stripes, []*readBuffer, its length is the power of 2
+	table atomic.Value
+	// The spin lock used when resizing or creating the stripes
+	tableBusy int32
+}
+
+func newStripedBuffer() *stripedBuffer {
+	return &stripedBuffer{}
+}
+
+func (b *stripedBuffer) stripes() []*readBuffer {
+	buffers, _ := b.table.Load().([]*readBuffer)
+	return buffers
+}
+
+// offer records the node in the stripe selected by the probe. The stripes are expanded if the
+// producers contend, and the node is dropped if no stripe accepts it within the attempts.
+func (b *stripedBuffer) offer(n *Node) bufferStatus {
+	probePtr := probePool.Get().(*uint32)
+	defer probePool.Put(probePtr)
+
+	buffers := b.stripes()
+	if len(buffers) == 0 {
+		return b.expandOrRetry(n, probePtr, true)
+	}
+	result := buffers[*probePtr&uint32(len(buffers)-1)].offer(n)
+	if result == failed {
+		return b.expandOrRetry(n, probePtr, false)
+	}
+	return result
+}
+
+// expandOrRetry handles the cases of initialization, creating stripes, and contention.
+// It follows the Striped64 of Java: rehash the probe on the first contention, then expand the
+// stripes when the contention repeats and there is room for growth.
+func (b *stripedBuffer) expandOrRetry(n *Node, probePtr *uint32, wasUncontended bool) bufferStatus {
+	var result bufferStatus = failed
+	collide := false
+	for attempt := 0; attempt < stripedBufferAttempts; attempt++ {
+		buffers := b.stripes()
+		if size := len(buffers); size > 0 {
+			buffer := buffers[*probePtr&uint32(size-1)]
+			if !wasUncontended {
+				// continue after rehash
+				wasUncontended = true
+			} else if result = buffer.offer(n); result != failed {
+				return result
+			} else if size >= MaxStripes || len(b.stripes()) != size {
+				// at max size or stale
+				collide = false
+			} else if !collide {
+				collide = true
+			} else if atomic.CompareAndSwapInt32(&b.tableBusy, 0, 1) {
+				if len(b.stripes()) == size {
+					b.table.Store(b.grow(buffers))
+				}
+				atomic.StoreInt32(&b.tableBusy, 0)
+				collide = false
+				// retry with expanded table
+				continue
+			}
+			*probePtr = advanceProbe(*probePtr)
+		} else if atomic.CompareAndSwapInt32(&b.tableBusy, 0, 1) {
+			init := false
+			if len(b.stripes()) == 0 {
+				buffer := newReadBuffer()
+				result = buffer.offer(n)
+				b.table.Store([]*readBuffer{buffer})
+				init = true
+			}
+			atomic.StoreInt32(&b.tableBusy, 0)
+			if init {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// grow returns the stripes doubled in size. The caller must hold the tableBusy lock.
+func (b *stripedBuffer) grow(buffers []*readBuffer) []*readBuffer {
+	expanded := make([]*readBuffer, len(buffers)<<1)
+	copy(expanded, buffers)
+	for i := len(buffers); i < len(expanded); i++ {
+		expanded[i] = newReadBuffer()
+	}
+	return expanded
+}
+
+// drainTo drains every stripe, sending each recorded node to the consumer.
+// The caller must ensure that a consumer has exclusive read access to the buffer.
+func (b *stripedBuffer) drainTo(consumer func(n *Node)) {
+	for _, buffer := range b.stripes() {
+		buffer.drainTo(consumer)
+	}
+}
@@ -0,0 +1,63 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_StripedBuffer(t *testing.T) {
+	t.Run("Test_StripedBuffer_lazyInit", func(t *testing.T) {
+		b := newStripedBuffer()
+		assert.Equal(t, 0, len(b.stripes()))
+		n := &Node{Key: []byte("louyuting")}
+		assert.True(t, b.offer(n) == success)
+		assert.Equal(t, 1, len(b.stripes()))
+
+		drained := 0
+		b.drainTo(func(e *Node) {
+			assert.True(t, e == n)
+			drained++
+		})
+		assert.Equal(t, 1, drained)
+	})
+
+	t.Run("Test_StripedBuffer_grow", func(t *testing.T) {
+		b := newStripedBuffer()
+		b.offer(&Node{})
+		buffers := b.stripes()
+		expanded := b.grow(buffers)
+		assert.Equal(t, 2, len(expanded))
+		assert.True(t, expanded[0] == buffers[0])
+		assert.NotNil(t, expanded[1])
+	})
+
+	t.Run("Test_StripedBuffer_concurrent", func(t *testing.T) {
+		b := newStripedBuffer()
+		var recorded int64
+		wg := sync.WaitGroup{}
+		for g := 0; g < 4*runtime.NumCPU(); g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 8; i++ {
+					if b.offer(&Node{}) == success {
+						atomic.AddInt64(&recorded, 1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		size := len(b.stripes())
+		assert.True(t, size >= 1 && size <= MaxStripes)
+		assert.True(t, size&(size-1) == 0)
+
+		drained := int64(0)
+		b.drainTo(func(e *Node) {
+			drained++
+		})
+		assert.Equal(t, atomic.LoadInt64(&recorded), drained)
+	})
+}
@@ -0,0 +1,47 @@
+package cocoa
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Ticker is a time source that returns the number of nanoseconds elapsed since a fixed but arbitrary
+// point in time. It is only useful to measure the elapsed time between two reads.
+type Ticker interface {
+	Read() int64
+}
+
+// systemTicker reads the monotonic clock of the system.
+type systemTicker struct {
+	start time.Time
+}
+
+var defaultSystemTicker = &systemTicker{start: time.Now()}
+
+// SystemTicker returns the Ticker that reads the monotonic clock of the system.
+func SystemTicker() Ticker {
+	return defaultSystemTicker
+}
+
+func (t *systemTicker) Read() int64 {
+	return int64(time.Since(t.start))
+}
+
+// FakeTicker is a Ticker whose time only moves when advanced, which makes the time-dependent
+// behavior testable without sleeping. It is safe for concurrent use.
+type FakeTicker struct {
+	nanos int64
+}
+
+func NewFakeTicker() *FakeTicker {
+	return &FakeTicker{}
+}
+
+func (t *FakeTicker) Read() int64 {
+	return atomic.LoadInt64(&t.nanos)
+}
+
+// Advance moves the time forward by d.
+func (t *FakeTicker) Advance(d time.Duration) {
+	atomic.AddInt64(&t.nanos, int64(d))
+}
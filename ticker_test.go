@@ -0,0 +1,24 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	t.Run("TestSystemTicker", func(t *testing.T) {
+		ticker := SystemTicker()
+		start := ticker.Read()
+		time.Sleep(time.Millisecond)
+		assert.True(t, ticker.Read()-start >= int64(time.Millisecond))
+	})
+
+	t.Run("TestFakeTicker", func(t *testing.T) {
+		ticker := NewFakeTicker()
+		assert.Equal(t, int64(0), ticker.Read())
+		ticker.Advance(time.Second)
+		ticker.Advance(time.Millisecond)
+		assert.Equal(t, int64(time.Second+time.Millisecond), ticker.Read())
+	})
+}
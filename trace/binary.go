@@ -0,0 +1,41 @@
+package trace
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+)
+
+const (
+	cloudPhysicsRecordSize = 8
+	twitterRecordSize      = 24
+)
+
+type cloudPhysicsReader struct {
+	r      io.Reader
+	record [cloudPhysicsRecordSize]byte
+}
+
+func (r *cloudPhysicsReader) Read() (Event, error) {
+	// a partial record at the end of the trace is io.ErrUnexpectedEOF
+	if _, err := io.ReadFull(r.r, r.record[:]); err != nil {
+		return Event{}, err
+	}
+	block := binary.BigEndian.Uint64(r.record[:])
+	return Event{Key: strconv.AppendUint(nil, block, 10)}, nil
+}
+
+type twitterReader struct {
+	r      io.Reader
+	record [twitterRecordSize]byte
+}
+
+func (r *twitterReader) Read() (Event, error) {
+	// a partial record at the end of the trace is io.ErrUnexpectedEOF
+	if _, err := io.ReadFull(r.r, r.record[:]); err != nil {
+		return Event{}, err
+	}
+	id := binary.LittleEndian.Uint64(r.record[4:])
+	size := binary.LittleEndian.Uint32(r.record[12:])
+	return Event{Key: strconv.AppendUint(nil, id, 10), Weight: int(size)}, nil
+}
@@ -0,0 +1,129 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The maximum length of a line, the urls of the request logs may be long.
+const maxLineSize = 1 << 20
+
+// lineReader reads the non-empty lines split into fields, and counts the lines for the errors.
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &lineReader{scanner: scanner}
+}
+
+// next returns the fields of the next non-empty line, or io.EOF.
+func (l *lineReader) next() ([]string, error) {
+	for l.scanner.Scan() {
+		l.line++
+		if fields := strings.Fields(l.scanner.Text()); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	if err := l.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (l *lineReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("trace: line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+type keysReader struct {
+	lines *lineReader
+}
+
+func (r *keysReader) Read() (Event, error) {
+	fields, err := r.lines.next()
+	if err != nil {
+		return Event{}, err
+	}
+	e := Event{Key: []byte(fields[0])}
+	if len(fields) > 1 {
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil || weight < 0 {
+			return Event{}, r.lines.errorf("illegal weight %q", fields[1])
+		}
+		e.Weight = weight
+	}
+	return e, nil
+}
+
+type arcReader struct {
+	lines *lineReader
+	// the next block and the remaining blocks of the current line
+	block, remaining uint64
+}
+
+func (r *arcReader) Read() (Event, error) {
+	for r.remaining == 0 {
+		fields, err := r.lines.next()
+		if err != nil {
+			return Event{}, err
+		}
+		if len(fields) < 2 {
+			return Event{}, r.lines.errorf("expected the starting block and the number of blocks")
+		}
+		block, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return Event{}, r.lines.errorf("illegal block %q", fields[0])
+		}
+		count, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return Event{}, r.lines.errorf("illegal number of blocks %q", fields[1])
+		}
+		r.block, r.remaining = block, count
+	}
+	e := Event{Key: strconv.AppendUint(nil, r.block, 10)}
+	r.block++
+	r.remaining--
+	return e, nil
+}
+
+type lirsReader struct {
+	lines *lineReader
+}
+
+func (r *lirsReader) Read() (Event, error) {
+	for {
+		fields, err := r.lines.next()
+		if err != nil {
+			return Event{}, err
+		}
+		if _, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			return Event{Key: []byte(fields[0])}, nil
+		}
+	}
+}
+
+type wikipediaReader struct {
+	lines *lineReader
+}
+
+func (r *wikipediaReader) Read() (Event, error) {
+	for {
+		fields, err := r.lines.next()
+		if err != nil {
+			return Event{}, err
+		}
+		if len(fields) < 3 {
+			return Event{}, r.lines.errorf("expected the counter, the timestamp and the url")
+		}
+		if len(fields) > 3 && fields[3] == "save" {
+			continue
+		}
+		return Event{Key: []byte(fields[2])}, nil
+	}
+}
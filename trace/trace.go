@@ -0,0 +1,135 @@
+// Package trace reads the key access traces of the published cache workloads as streams of events.
+package trace
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Event is an access of a key in a trace.
+type Event struct {
+	Key []byte
+	// the weight of the entry, zero if the format has no weight
+	Weight int
+}
+
+// Reader reads the events of a trace one by one.
+type Reader interface {
+	// Read returns the next event, or io.EOF at the end of the trace.
+	Read() (Event, error)
+}
+
+// Format is the format of a trace.
+type Format int32
+
+const (
+	// Keys is a key per line, optionally followed by its weight.
+	Keys Format = iota
+	// ARC is the text trace of the ARC paper, a line has the starting block, the number of blocks and two
+	// ignored fields. An event is read for each block.
+	ARC
+	// LIRS is the text trace of the LIRS paper, a block number per line. The lines not being a number, e.g.
+	// the "*" separators, are skipped.
+	LIRS
+	// Wikipedia is the request log of the Wikibench traces, a line has a counter, a timestamp, the url and
+	// the save flag. The key is the url, the save requests are skipped.
+	Wikipedia
+	// CloudPhysics is the binary trace of the CloudPhysics block traces, a record is a big-endian 64-bit
+	// block number.
+	CloudPhysics
+	// Twitter is the binary trace of the Twitter cache traces in the oracleGeneral layout, a record is a
+	// little-endian 32-bit timestamp, 64-bit object id, 32-bit object size and 64-bit next access time.
+	// The key is the object id and the weight is the object size.
+	Twitter
+)
+
+var formatNames = [...]string{"keys", "arc", "lirs", "wikipedia", "cloudphysics", "twitter"}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return "unknown"
+	}
+	return formatNames[f]
+}
+
+// ParseFormat returns the format of the name, which is case-insensitive.
+func ParseFormat(name string) (Format, error) {
+	for i, formatName := range formatNames {
+		if strings.EqualFold(name, formatName) {
+			return Format(i), nil
+		}
+	}
+	return 0, fmt.Errorf("trace: unknown format %q", name)
+}
+
+// NewReader returns the reader of the trace in the format, the gzip compressed trace is decompressed.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+
+	switch format {
+	case Keys:
+		return &keysReader{lines: newLineReader(br)}, nil
+	case ARC:
+		return &arcReader{lines: newLineReader(br)}, nil
+	case LIRS:
+		return &lirsReader{lines: newLineReader(br)}, nil
+	case Wikipedia:
+		return &wikipediaReader{lines: newLineReader(br)}, nil
+	case CloudPhysics:
+		return &cloudPhysicsReader{r: br}, nil
+	case Twitter:
+		return &twitterReader{r: br}, nil
+	default:
+		return nil, fmt.Errorf("trace: unknown format %d", format)
+	}
+}
+
+// ReadCloser is the Reader of a trace file, which is closed by Close.
+type ReadCloser struct {
+	Reader
+	f *os.File
+}
+
+// Close closes the trace file.
+func (r *ReadCloser) Close() error {
+	return r.f.Close()
+}
+
+// Open opens the trace file in the format.
+func Open(path string, format Format) (*ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f, format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &ReadCloser{Reader: r, f: f}, nil
+}
+
+// ForEach reads the events until the end of the trace, calling the fn for each event.
+func ForEach(r Reader, fn func(e Event)) error {
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fn(e)
+	}
+}
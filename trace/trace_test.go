@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r Reader) []Event {
+	var events []Event
+	assert.Nil(t, ForEach(r, func(e Event) {
+		events = append(events, e)
+	}))
+	return events
+}
+
+func keysOf(events []Event) []string {
+	var keys []string
+	for _, e := range events {
+		keys = append(keys, string(e.Key))
+	}
+	return keys
+}
+
+func TestReader(t *testing.T) {
+	t.Run("keys", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader("a\n\nb 10\n  c  3  \n"), Keys)
+		assert.Nil(t, err)
+		events := readAll(t, r)
+		assert.Equal(t, []Event{{[]byte("a"), 0}, {[]byte("b"), 10}, {[]byte("c"), 3}}, events)
+
+		r, _ = NewReader(strings.NewReader("a\nb x\n"), Keys)
+		_, err = r.Read()
+		assert.Nil(t, err)
+		_, err = r.Read()
+		assert.EqualError(t, err, `trace: line 2: illegal weight "x"`)
+	})
+
+	t.Run("arc", func(t *testing.T) {
+		r, _ := NewReader(strings.NewReader("10 3 0 1\n5 1 0 2\n7 0 0 3\n"), ARC)
+		assert.Equal(t, []string{"10", "11", "12", "5"}, keysOf(readAll(t, r)))
+
+		r, _ = NewReader(strings.NewReader("x 1 0 1\n"), ARC)
+		_, err := r.Read()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("lirs", func(t *testing.T) {
+		r, _ := NewReader(strings.NewReader("1\n*\n2\n1\n"), LIRS)
+		assert.Equal(t, []string{"1", "2", "1"}, keysOf(readAll(t, r)))
+	})
+
+	t.Run("wikipedia", func(t *testing.T) {
+		log := "1 1190146243.326 http://en.wikipedia.org/wiki/A -\n" +
+			"2 1190146243.327 http://en.wikipedia.org/w/index.php?title=B save\n" +
+			"3 1190146243.328 http://en.wikipedia.org/wiki/C -\n"
+		r, _ := NewReader(strings.NewReader(log), Wikipedia)
+		assert.Equal(t, []string{"http://en.wikipedia.org/wiki/A", "http://en.wikipedia.org/wiki/C"},
+			keysOf(readAll(t, r)))
+	})
+
+	t.Run("cloudphysics", func(t *testing.T) {
+		var buf bytes.Buffer
+		for _, block := range []uint64{7, 1 << 40} {
+			binary.Write(&buf, binary.BigEndian, block)
+		}
+		r, _ := NewReader(bytes.NewReader(buf.Bytes()), CloudPhysics)
+		assert.Equal(t, []string{"7", "1099511627776"}, keysOf(readAll(t, r)))
+
+		r, _ = NewReader(bytes.NewReader(buf.Bytes()[:12]), CloudPhysics)
+		_, err := r.Read()
+		assert.Nil(t, err)
+		_, err = r.Read()
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+
+	t.Run("twitter", func(t *testing.T) {
+		var buf bytes.Buffer
+		for i, id := range []uint64{42, 43} {
+			binary.Write(&buf, binary.LittleEndian, uint32(i))
+			binary.Write(&buf, binary.LittleEndian, id)
+			binary.Write(&buf, binary.LittleEndian, uint32(100*(i+1)))
+			binary.Write(&buf, binary.LittleEndian, int64(-1))
+		}
+		r, _ := NewReader(&buf, Twitter)
+		assert.Equal(t, []Event{{[]byte("42"), 100}, {[]byte("43"), 200}}, readAll(t, r))
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "trace")
+		assert.Nil(t, err)
+		defer os.Remove(f.Name())
+		gz := gzip.NewWriter(f)
+		gz.Write([]byte("a\nb\n"))
+		gz.Close()
+		f.Close()
+
+		r, err := Open(f.Name(), Keys)
+		assert.Nil(t, err)
+		defer r.Close()
+		assert.Equal(t, []string{"a", "b"}, keysOf(readAll(t, r)))
+	})
+
+	t.Run("format", func(t *testing.T) {
+		for f := Keys; f <= Twitter; f++ {
+			parsed, err := ParseFormat(strings.ToUpper(f.String()))
+			assert.Nil(t, err)
+			assert.Equal(t, f, parsed)
+		}
+		_, err := ParseFormat("csv")
+		assert.NotNil(t, err)
+		_, err = NewReader(strings.NewReader(""), Format(100))
+		assert.NotNil(t, err)
+	})
+}
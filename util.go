@@ -2,12 +2,13 @@ package cocoa
 
 import (
 	"sync/atomic"
-	"time"
 	"unsafe"
 )
 
 const (
 	PtrSize = 4 << (^uintptr(0) >> 63)
+	// The assumed size of a cache line, used to pad the hot fields against false sharing.
+	CacheLineSize = 64
 )
 
 // SliceHeader is a safe version of SliceHeader used within this project.
@@ -27,13 +28,6 @@ func bytesToString(src []byte) *string {
 	return (*string)(unsafe.Pointer(&src))
 }
 
-const UnixTimeUnitOffset = uint64(time.Millisecond / time.Nanosecond)
-
-// Returns the current Unix timestamp in milliseconds.
-func CurrentTimeMillis() uint64 {
-	return uint64(time.Now().UnixNano()) / UnixTimeUnitOffset
-}
-
 func ceilingPowerOfTwo(s int) int {
 	n := s - 1
 	n |= n >> 1
@@ -91,3 +85,17 @@ func (b *AtomicBool) Get() bool {
 	}
 	return false
 }
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
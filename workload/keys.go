@@ -0,0 +1,142 @@
+package workload
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// KeyGenerator generates the key ids of a workload, a generator is not safe for concurrent use.
+type KeyGenerator interface {
+	// Next returns the next key id in [0, items).
+	Next() uint64
+}
+
+type uniform struct {
+	rand  *rand.Rand
+	items uint64
+}
+
+// NewUniform returns the generator choosing the items uniformly.
+func NewUniform(r *rand.Rand, items uint64) KeyGenerator {
+	return &uniform{rand: r, items: items}
+}
+
+func (g *uniform) Next() uint64 {
+	return uint64(g.rand.Int63n(int64(g.items)))
+}
+
+// zipfian is the generator of the YCSB, which supports the constant in (0, 1) unlike rand.Zipf.
+type zipfian struct {
+	rand  *rand.Rand
+	items uint64
+	theta float64
+	alpha float64
+	zetan float64
+	eta   float64
+}
+
+// NewZipfian returns the generator choosing the item i with the probability proportional to 1/(i+1)^theta,
+// so the item 0 is the most popular. The theta is in (0, 1), e.g. 0.99 of the YCSB.
+// The construction takes O(items) time.
+func NewZipfian(r *rand.Rand, items uint64, theta float64) KeyGenerator {
+	zetan := zeta(items, theta)
+	return &zipfian{
+		rand:  r,
+		items: items,
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zetan: zetan,
+		eta:   (1 - math.Pow(2/float64(items), 1-theta)) / (1 - zeta(2, theta)/zetan),
+	}
+}
+
+func zeta(n uint64, theta float64) float64 {
+	sum := 0.0
+	for i := uint64(1); i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+	return sum
+}
+
+func (g *zipfian) Next() uint64 {
+	u := g.rand.Float64()
+	uz := u * g.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, g.theta) {
+		return 1
+	}
+	id := uint64(float64(g.items) * math.Pow(g.eta*u-g.eta+1, g.alpha))
+	if id >= g.items {
+		id = g.items - 1
+	}
+	return id
+}
+
+type scrambledZipfian struct {
+	zipfian KeyGenerator
+	items   uint64
+}
+
+// NewScrambledZipfian returns the zipfian generator whose popular items are scattered over the key space
+// by hashing, instead of being the smallest ids.
+func NewScrambledZipfian(r *rand.Rand, items uint64, theta float64) KeyGenerator {
+	return &scrambledZipfian{zipfian: NewZipfian(r, items, theta), items: items}
+}
+
+func (g *scrambledZipfian) Next() uint64 {
+	id := g.zipfian.Next()
+	h := fnv.New64a()
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(id >> (8 * uint(i)))
+	}
+	h.Write(b[:])
+	return h.Sum64() % g.items
+}
+
+type hotspot struct {
+	rand          *rand.Rand
+	items         uint64
+	hotItems      uint64
+	hotOpFraction float64
+}
+
+// NewHotspot returns the generator choosing the hot items, the first hotFraction of the items, for the
+// hotOpFraction of the operations, and the cold items for the others, uniformly within each set.
+func NewHotspot(r *rand.Rand, items uint64, hotFraction, hotOpFraction float64) KeyGenerator {
+	hotItems := uint64(float64(items) * hotFraction)
+	if hotItems < 1 {
+		hotItems = 1
+	}
+	if hotItems > items {
+		hotItems = items
+	}
+	return &hotspot{rand: r, items: items, hotItems: hotItems, hotOpFraction: hotOpFraction}
+}
+
+func (g *hotspot) Next() uint64 {
+	if g.hotItems == g.items || g.rand.Float64() < g.hotOpFraction {
+		return uint64(g.rand.Int63n(int64(g.hotItems)))
+	}
+	return g.hotItems + uint64(g.rand.Int63n(int64(g.items-g.hotItems)))
+}
+
+type loopingScan struct {
+	items uint64
+	next  uint64
+}
+
+// NewLoopingScan returns the generator scanning the items in order repeatedly, the worst case of the LRU
+// when the items exceed the cache.
+func NewLoopingScan(items uint64) KeyGenerator {
+	return &loopingScan{items: items}
+}
+
+func (g *loopingScan) Next() uint64 {
+	id := g.next
+	g.next = (g.next + 1) % g.items
+	return id
+}
@@ -0,0 +1,50 @@
+package workload
+
+import (
+	"math/rand"
+)
+
+// SizeGenerator generates the value sizes of a workload.
+type SizeGenerator interface {
+	Next() int
+}
+
+type constantSize int
+
+// NewConstantSize returns the generator of the same size.
+func NewConstantSize(size int) SizeGenerator {
+	return constantSize(size)
+}
+
+func (s constantSize) Next() int {
+	return int(s)
+}
+
+type uniformSize struct {
+	rand     *rand.Rand
+	min, max int
+}
+
+// NewUniformSize returns the generator of the sizes uniformly in [min, max].
+func NewUniformSize(r *rand.Rand, min, max int) SizeGenerator {
+	return &uniformSize{rand: r, min: min, max: max}
+}
+
+func (s *uniformSize) Next() int {
+	return s.min + s.rand.Intn(s.max-s.min+1)
+}
+
+type exponentialSize struct {
+	rand *rand.Rand
+	mean float64
+}
+
+// NewExponentialSize returns the generator of the exponentially distributed sizes of the mean, which are
+// at least 1, so most values are small and a few are large.
+func NewExponentialSize(r *rand.Rand, mean int) SizeGenerator {
+	return &exponentialSize{rand: r, mean: float64(mean)}
+}
+
+func (s *exponentialSize) Next() int {
+	return 1 + int(s.rand.ExpFloat64()*(s.mean-1))
+}
@@ -0,0 +1,124 @@
+// Package workload generates the synthetic operation streams of the cache workloads, for the simulator and
+// for the benchmarks.
+package workload
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// OpType is the type of an operation.
+type OpType int32
+
+const (
+	Read OpType = iota
+	Write
+	Delete
+)
+
+// String returns the name of the operation type.
+func (t OpType) String() string {
+	switch t {
+	case Read:
+		return "Read"
+	case Write:
+		return "Write"
+	case Delete:
+		return "Delete"
+	default:
+		return "Unknown"
+	}
+}
+
+// Op is an operation of a workload.
+type Op struct {
+	Type OpType
+	Key  []byte
+	// the size of the value written, or loaded if a read misses, zero for the deletes
+	ValueSize int
+}
+
+// Generator generates the operations of a workload, a generator is not safe for concurrent use.
+type Generator interface {
+	Next() Op
+}
+
+// Mix is the ratio of the reads, writes and deletes, which are normalized by their sum.
+type Mix struct {
+	Read, Write, Delete float64
+}
+
+// ReadOnly is the mix of reads only, as replaying a trace.
+var ReadOnly = Mix{Read: 1}
+
+// Workload generates the operations of the keys by the mix, with the value sizes for the reads and writes.
+type Workload struct {
+	rand  *rand.Rand
+	keys  KeyGenerator
+	sizes SizeGenerator
+	// the cumulative thresholds of the read and the write in [0, 1]
+	readThreshold, writeThreshold float64
+}
+
+// New returns the workload of the keys and the mix, the values are of size 1 if the sizes is nil.
+func New(r *rand.Rand, keys KeyGenerator, mix Mix, sizes SizeGenerator) *Workload {
+	total := mix.Read + mix.Write + mix.Delete
+	if total <= 0 {
+		mix, total = ReadOnly, 1
+	}
+	if sizes == nil {
+		sizes = NewConstantSize(1)
+	}
+	return &Workload{
+		rand:           r,
+		keys:           keys,
+		sizes:          sizes,
+		readThreshold:  mix.Read / total,
+		writeThreshold: (mix.Read + mix.Write) / total,
+	}
+}
+
+func (w *Workload) Next() Op {
+	op := Op{Key: strconv.AppendUint(nil, w.keys.Next(), 10)}
+	if u := w.rand.Float64(); u < w.readThreshold {
+		op.Type = Read
+		op.ValueSize = w.sizes.Next()
+	} else if u < w.writeThreshold {
+		op.Type = Write
+		op.ValueSize = w.sizes.Next()
+	} else {
+		op.Type = Delete
+	}
+	return op
+}
+
+// Phase is a generator used for a number of operations.
+type Phase struct {
+	Generator Generator
+	// the number of operations of the phase, the phase never ends if it is not positive
+	Ops int
+}
+
+type phases struct {
+	phases  []Phase
+	current int
+	ops     int
+}
+
+// NewPhases returns the generator switching to the next phase after the operations of a phase, and to the
+// first phase after the last one, e.g. a zipfian phase followed by a scan. It panics without any phase.
+func NewPhases(p ...Phase) Generator {
+	if len(p) == 0 {
+		panic("workload: no phases")
+	}
+	return &phases{phases: p}
+}
+
+func (p *phases) Next() Op {
+	for p.phases[p.current].Ops > 0 && p.ops >= p.phases[p.current].Ops {
+		p.current = (p.current + 1) % len(p.phases)
+		p.ops = 0
+	}
+	p.ops++
+	return p.phases[p.current].Generator.Next()
+}
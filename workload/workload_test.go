@@ -0,0 +1,154 @@
+package workload
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestKeyGenerators(t *testing.T) {
+	const items = 1000
+	t.Run("in range", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		for name, g := range map[string]KeyGenerator{
+			"uniform":           NewUniform(r, items),
+			"zipfian":           NewZipfian(r, items, 0.99),
+			"scrambled zipfian": NewScrambledZipfian(r, items, 0.99),
+			"hotspot":           NewHotspot(r, items, 0.2, 0.8),
+			"looping scan":      NewLoopingScan(items),
+		} {
+			for i := 0; i < 10000; i++ {
+				assert.True(t, g.Next() < items, name)
+			}
+		}
+	})
+
+	t.Run("zipfian is skewed to the small ids", func(t *testing.T) {
+		g := NewZipfian(rand.New(rand.NewSource(1)), items, 0.99)
+		counts := make([]int, items)
+		for i := 0; i < 100000; i++ {
+			counts[g.Next()]++
+		}
+		assert.True(t, counts[0] > counts[1])
+		assert.True(t, counts[1] > counts[10])
+		assert.True(t, counts[10] > counts[items-1])
+		// the top 10% items take most of the accesses
+		top := 0
+		for _, c := range counts[:items/10] {
+			top += c
+		}
+		assert.True(t, top > 50000)
+	})
+
+	t.Run("scrambled zipfian scatters the popular ids", func(t *testing.T) {
+		g := NewScrambledZipfian(rand.New(rand.NewSource(1)), items, 0.99)
+		counts := make(map[uint64]int)
+		for i := 0; i < 100000; i++ {
+			counts[g.Next()]++
+		}
+		var hottest uint64
+		for id, c := range counts {
+			if c > counts[hottest] {
+				hottest = id
+			}
+		}
+		assert.NotEqual(t, uint64(0), hottest)
+		assert.True(t, counts[hottest] > 1000)
+	})
+
+	t.Run("hotspot", func(t *testing.T) {
+		g := NewHotspot(rand.New(rand.NewSource(1)), items, 0.1, 0.9)
+		hot := 0
+		for i := 0; i < 10000; i++ {
+			if g.Next() < items/10 {
+				hot++
+			}
+		}
+		assert.InDelta(t, 9000, hot, 300)
+	})
+
+	t.Run("looping scan", func(t *testing.T) {
+		g := NewLoopingScan(3)
+		var ids []uint64
+		for i := 0; i < 7; i++ {
+			ids = append(ids, g.Next())
+		}
+		assert.Equal(t, []uint64{0, 1, 2, 0, 1, 2, 0}, ids)
+	})
+}
+
+func TestSizeGenerators(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	assert.Equal(t, 7, NewConstantSize(7).Next())
+
+	uniform := NewUniformSize(r, 10, 20)
+	for i := 0; i < 1000; i++ {
+		size := uniform.Next()
+		assert.True(t, size >= 10 && size <= 20)
+	}
+
+	exponential := NewExponentialSize(r, 100)
+	sum := 0
+	for i := 0; i < 10000; i++ {
+		size := exponential.Next()
+		assert.True(t, size >= 1)
+		sum += size
+	}
+	assert.InDelta(t, 100, sum/10000, 10)
+}
+
+func TestWorkload(t *testing.T) {
+	t.Run("mix", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		w := New(r, NewUniform(r, 100), Mix{Read: 8, Write: 1, Delete: 1}, NewConstantSize(5))
+		counts := make(map[OpType]int)
+		for i := 0; i < 10000; i++ {
+			op := w.Next()
+			counts[op.Type]++
+			id, err := strconv.Atoi(string(op.Key))
+			assert.Nil(t, err)
+			assert.True(t, id < 100)
+			if op.Type == Delete {
+				assert.Equal(t, 0, op.ValueSize)
+			} else {
+				assert.Equal(t, 5, op.ValueSize)
+			}
+		}
+		assert.InDelta(t, 8000, counts[Read], 300)
+		assert.InDelta(t, 1000, counts[Write], 200)
+		assert.InDelta(t, 1000, counts[Delete], 200)
+	})
+
+	t.Run("read only by default", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		w := New(r, NewLoopingScan(10), Mix{}, nil)
+		for i := 0; i < 100; i++ {
+			op := w.Next()
+			assert.Equal(t, Read, op.Type)
+			assert.Equal(t, 1, op.ValueSize)
+		}
+	})
+
+	t.Run("phases", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		reads := New(r, NewLoopingScan(10), ReadOnly, nil)
+		deletes := New(r, NewLoopingScan(10), Mix{Delete: 1}, nil)
+		p := NewPhases(Phase{Generator: reads, Ops: 3}, Phase{Generator: deletes, Ops: 2})
+		var types []OpType
+		for i := 0; i < 7; i++ {
+			types = append(types, p.Next().Type)
+		}
+		assert.Equal(t, []OpType{Read, Read, Read, Delete, Delete, Read, Read}, types)
+
+		// a phase without a positive number of operations never ends
+		p = NewPhases(Phase{Generator: reads, Ops: 1}, Phase{Generator: deletes})
+		assert.Equal(t, Read, p.Next().Type)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, Delete, p.Next().Type)
+		}
+		p = NewPhases(Phase{Generator: reads}, Phase{Generator: deletes})
+		assert.Equal(t, Read, p.Next().Type)
+		assert.Panics(t, func() { NewPhases() })
+	})
+}
@@ -0,0 +1,122 @@
+package cocoa
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// The number of tasks a chunk of the write buffer could store.
+	writeBufferChunkSize = 64
+)
+
+// writeBufferChunk is a fixed-size array of the write buffer's linked list.
+// prev is kept until the consumer has moved past the chunk, so that a slow producer which
+// observed a newer producer chunk can walk back to the chunk of its reserved index.
+type writeBufferChunk struct {
+	// The sequence number of this chunk, chunk n stores the indexes [n*size, (n+1)*size)
+	index uint64
+	prev  unsafe.Pointer
+	next  unsafe.Pointer
+	slots [writeBufferChunkSize]unsafe.Pointer
+}
+
+func newWriteBufferChunk(index uint64, prev *writeBufferChunk) *writeBufferChunk {
+	return &writeBufferChunk{
+		index: index,
+		prev:  unsafe.Pointer(prev),
+	}
+}
+
+// writeBuffer is an unbounded-growable, multi producer and single consumer queue of tasks,
+// implemented as a linked list of chunked arrays. The producers reserve an index by atomic add,
+// so an offer never spins on contention, and a new chunk is linked when the reserved index
+// exceeds the last chunk.
+// The number of pending tasks is bounded by maxCapacity: offer returns false once the buffer
+// is full, and the caller must apply the back-pressure, e.g. by performing the maintenance itself.
+// The bound is approximate, it may be exceeded by the number of concurrent producers.
+type writeBuffer struct {
+	producerIndex uint64
+	_             [CacheLineSize - 8]byte
+	producerChunk unsafe.Pointer
+	_             [CacheLineSize - PtrSize]byte
+	consumerIndex uint64
+	_             [CacheLineSize - 8]byte
+	// only accessed by the consumer
+	consumerChunk *writeBufferChunk
+	maxCapacity   uint64
+}
+
+func newWriteBuffer(maxCapacity int) *writeBuffer {
+	chunk := newWriteBufferChunk(0, nil)
+	return &writeBuffer{
+		producerChunk: unsafe.Pointer(chunk),
+		consumerChunk: chunk,
+		maxCapacity:   uint64(maxCapacity),
+	}
+}
+
+// size returns the approximate number of pending tasks.
+func (b *writeBuffer) size() int {
+	consumerIndex := atomic.LoadUint64(&b.consumerIndex)
+	producerIndex := atomic.LoadUint64(&b.producerIndex)
+	return int(producerIndex - consumerIndex)
+}
+
+// offer inserts the task at the tail of this buffer. It returns false if the buffer is full.
+func (b *writeBuffer) offer(t task) bool {
+	if uint64(b.size()) >= b.maxCapacity {
+		return false
+	}
+	idx := atomic.AddUint64(&b.producerIndex, 1) - 1
+	chunkIndex := idx / writeBufferChunkSize
+
+	chunkPtr := atomic.LoadPointer(&b.producerChunk)
+	chunk := (*writeBufferChunk)(chunkPtr)
+	for chunk.index > chunkIndex {
+		chunk = (*writeBufferChunk)(atomic.LoadPointer(&chunk.prev))
+	}
+	for chunk.index < chunkIndex {
+		next := atomic.LoadPointer(&chunk.next)
+		if next == nil {
+			// the losers of the cas discard their chunk and use the winner's
+			newChunk := unsafe.Pointer(newWriteBufferChunk(chunk.index+1, chunk))
+			if !atomic.CompareAndSwapPointer(&chunk.next, nil, newChunk) {
+				newChunk = atomic.LoadPointer(&chunk.next)
+			}
+			next = newChunk
+		}
+		// advance the producer chunk, it is fine to fail if another producer did it
+		atomic.CompareAndSwapPointer(&b.producerChunk, unsafe.Pointer(chunk), next)
+		chunk = (*writeBufferChunk)(next)
+	}
+	atomic.StorePointer(&chunk.slots[idx%writeBufferChunkSize], unsafe.Pointer(&t))
+	return true
+}
+
+// poll removes and returns the task at the head of buffer. It returns nil if the
+// buffer is empty or the head task is not published yet. It must only be called by a single consumer.
+func (b *writeBuffer) poll() task {
+	idx := atomic.LoadUint64(&b.consumerIndex)
+	chunk := b.consumerChunk
+	if chunk.index != idx/writeBufferChunkSize {
+		next := atomic.LoadPointer(&chunk.next)
+		if next == nil {
+			// not linked yet
+			return nil
+		}
+		chunk = (*writeBufferChunk)(next)
+		// the consumed chunk is unreachable from now on
+		atomic.StorePointer(&chunk.prev, nil)
+		b.consumerChunk = chunk
+	}
+	slot := &chunk.slots[idx%writeBufferChunkSize]
+	e := atomic.LoadPointer(slot)
+	if e == nil {
+		// not published yet
+		return nil
+	}
+	atomic.StorePointer(slot, nil)
+	atomic.StoreUint64(&b.consumerIndex, idx+1)
+	return *(*task)(e)
+}
@@ -0,0 +1,86 @@
+package cocoa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type countTask struct {
+	id    int
+	count *int
+}
+
+func (t *countTask) run() {
+	*t.count++
+}
+
+func Test_WriteBuffer(t *testing.T) {
+	t.Run("Test_WriteBuffer_growAcrossChunks", func(t *testing.T) {
+		b := newWriteBuffer(1024)
+		count := 0
+		total := 3*writeBufferChunkSize + 7
+		for i := 0; i < total; i++ {
+			assert.True(t, b.offer(&countTask{id: i, count: &count}))
+		}
+		assert.Equal(t, total, b.size())
+		for i := 0; i < total; i++ {
+			tk := b.poll()
+			assert.Equal(t, i, tk.(*countTask).id)
+			tk.run()
+		}
+		assert.Nil(t, b.poll())
+		assert.Equal(t, total, count)
+		assert.Equal(t, 0, b.size())
+	})
+
+	t.Run("Test_WriteBuffer_backPressure", func(t *testing.T) {
+		b := newWriteBuffer(writeBufferChunkSize)
+		count := 0
+		for i := 0; i < writeBufferChunkSize; i++ {
+			assert.True(t, b.offer(&countTask{count: &count}))
+		}
+		assert.False(t, b.offer(&countTask{count: &count}))
+		assert.NotNil(t, b.poll())
+		assert.True(t, b.offer(&countTask{count: &count}))
+	})
+
+	t.Run("Test_WriteBuffer_concurrentProducers", func(t *testing.T) {
+		producers := 8
+		perProducer := 1000
+		b := newWriteBuffer(producers * perProducer)
+		wg := sync.WaitGroup{}
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := 0; i < perProducer; i++ {
+					b.offer(&countTask{id: p*perProducer + i})
+				}
+			}(p)
+		}
+
+		seen := make(map[int]bool)
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		for finished := false; ; {
+			tk := b.poll()
+			if tk != nil {
+				seen[tk.(*countTask).id] = true
+				continue
+			}
+			if finished {
+				break
+			}
+			select {
+			case <-done:
+				finished = true
+			default:
+			}
+		}
+		assert.Equal(t, producers*perProducer, len(seen))
+	})
+}
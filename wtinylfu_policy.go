@@ -0,0 +1,203 @@
+package cocoa
+
+// wTinyLFUPolicy is the Window TinyLFU policy. New entries are added to the window, the entries evicted from
+// the window are candidates for the probation space of the main space, and are admitted by the
+// AdmissionPolicy against the victim of the probation space. An entry accessed in the probation space is
+// promoted to the protected space.
+type wTinyLFUPolicy struct {
+	maximum int
+
+	windowDeque    *AccessOrderDeque
+	probationDeque *AccessOrderDeque
+	protectedDeque *AccessOrderDeque
+
+	// the window deque weighted size
+	windowWeightedSize int
+	windowMaximum      int
+
+	// the protected deque weighted size
+	mainProtectedWeightedSize int
+	mainProtectedMaximum      int
+
+	admission AdmissionPolicy
+}
+
+// newWTinyLFUPolicy returns the policy whose window is 1% of the maximum, and whose protected space is 80%
+// of the main space.
+func newWTinyLFUPolicy(maximum int, admission AdmissionPolicy) *wTinyLFUPolicy {
+	windowMaximum := maximum / 100
+	if windowMaximum < 1 {
+		windowMaximum = 1
+	}
+	return &wTinyLFUPolicy{
+		maximum:              maximum,
+		windowDeque:          &AccessOrderDeque{},
+		probationDeque:       &AccessOrderDeque{},
+		protectedDeque:       &AccessOrderDeque{},
+		windowMaximum:        windowMaximum,
+		mainProtectedMaximum: (maximum - windowMaximum) * 8 / 10,
+		admission:            admission,
+	}
+}
+
+func (p *wTinyLFUPolicy) OnAdd(n *Node) {
+	n.makeIn(Window)
+	p.windowWeightedSize += n.weight
+	// insert to tail
+	p.windowDeque.PushBack(n)
+}
+
+// OnAccess updates the node's location in the policy.
+func (p *wTinyLFUPolicy) OnAccess(n *Node) {
+	if n.inWindow() && p.windowDeque.Contains(n) {
+		p.windowDeque.MoveToBack(n)
+	} else if n.inMainProbation() && p.probationDeque.Contains(n) {
+		p.mainProtectedWeightedSize += n.weight
+		p.probationDeque.Remove(n)
+		p.protectedDeque.PushBack(n)
+		n.makeIn(Protected)
+	} else if n.inMainProtected() && p.protectedDeque.Contains(n) {
+		p.protectedDeque.MoveToBack(n)
+	}
+}
+
+func (p *wTinyLFUPolicy) OnUpdate(n *Node, weightDiff int) {
+	if n.inWindow() && p.windowDeque.Contains(n) {
+		p.windowWeightedSize += weightDiff
+	} else if n.inMainProtected() && p.protectedDeque.Contains(n) {
+		p.mainProtectedWeightedSize += weightDiff
+	}
+}
+
+func (p *wTinyLFUPolicy) OnRemove(n *Node) {
+	if n.inWindow() {
+		if p.windowDeque.Contains(n) {
+			p.windowDeque.Remove(n)
+			p.windowWeightedSize -= n.weight
+		}
+	} else if n.inMainProbation() {
+		p.probationDeque.Remove(n)
+	} else if p.protectedDeque.Contains(n) {
+		p.protectedDeque.Remove(n)
+		p.mainProtectedWeightedSize -= n.weight
+	}
+}
+
+func (p *wTinyLFUPolicy) Evict(overweight func() bool, evict func(n *Node)) {
+	candidates := p.evictFromWindow()
+	p.evictFromMain(candidates, overweight, evict)
+}
+
+func (p *wTinyLFUPolicy) accessOrderDeques() []*AccessOrderDeque {
+	return []*AccessOrderDeque{p.windowDeque, p.probationDeque, p.protectedDeque}
+}
+
+// Evicts entries from the window space into the main space while the window size exceeds a maximum.
+// return the number of candidate entries evicted from the window space
+func (p *wTinyLFUPolicy) evictFromWindow() int {
+	candidateNum := 0
+	node := p.windowDeque.GetFront()
+	for p.windowWeightedSize > p.windowMaximum {
+		if node == nil {
+			break
+		}
+		next := node.nextNode()
+		p.windowDeque.Remove(node)
+		p.probationDeque.PushBack(node)
+		node.makeIn(Probation)
+		candidateNum++
+		p.windowWeightedSize = p.windowWeightedSize - node.weight
+		node = next
+	}
+	return candidateNum
+}
+
+// Evicts entries from the main space if the cache exceeds the maximum capacity. The main space
+// determines whether admitting an entry (coming from the window space) is preferable to retaining
+// the eviction policy's victim. This is decision is made using a frequency filter so that the
+// least frequently used entry is removed.
+//
+// The window space candidates were previously placed in the MRU position and the eviction
+// policy's victim is at the LRU position. The two ends of the queue are evaluated while an
+// eviction is required. The number of remaining candidates is provided and decremented on
+// eviction, so that when there are no more candidates the victim is evicted.
+func (p *wTinyLFUPolicy) evictFromMain(candidates int, overweight func() bool, evictNode func(n *Node)) {
+	victimQueue := Probation
+	victim := p.probationDeque.GetFront()
+	candidate := p.probationDeque.GetBack()
+
+	for overweight() {
+		// Stop trying to evict candidates from window deque and always prefer the victim
+		if candidates == 0 {
+			candidate = nil
+		}
+		// Try evicting from the protected and window queues
+		if candidate == nil && victim == nil {
+			// Probation deque is empty now, try to evict from Protected deque
+			if victimQueue == Probation {
+				victim = p.protectedDeque.GetFront()
+				victimQueue = Protected
+				continue
+			} else if victimQueue == Protected {
+				// Both Probation deque and Protected deque are empty, try to evict from Window deque
+				victim = p.windowDeque.GetFront()
+				victimQueue = Window
+				continue
+			}
+			// The pending operations will adjust the size to reflect the correct weight
+			break
+		}
+
+		// Evict immediately if only one of the entries is present
+		if victim == nil {
+			previous := candidate.prevNode()
+			evict := candidate
+			candidate = previous
+			candidates--
+			evictNode(evict)
+			continue
+		} else if candidate == nil {
+			// candidate is nil, always prefer to evict victim from Probation or Protected
+			evict := victim
+			victim = victim.nextNode()
+			evictNode(evict)
+			continue
+		}
+
+		// Evict immediately if an entry was collected
+		victimKey := victim.Key
+		candidateKey := candidate.Key
+		if len(victimKey) == 0 {
+			evict := victim
+			victim = victim.nextNode()
+			evictNode(evict)
+			continue
+		} else if len(candidateKey) == 0 {
+			candidates--
+			evict := candidate
+			candidate = candidate.prevNode()
+			evictNode(evict)
+			continue
+		}
+		if candidate.weight > p.maximum {
+			candidates--
+			evict := candidate
+			candidate = candidate.prevNode()
+			evictNode(evict)
+			continue
+		}
+
+		// Evict the entry with the lowest frequency
+		candidates--
+		if p.admission.Admit(candidate, victim) {
+			evict := victim
+			victim = victim.nextNode()
+			evictNode(evict)
+			candidate = candidate.prevNode()
+		} else {
+			evict := candidate
+			candidate = candidate.prevNode()
+			evictNode(evict)
+		}
+	}
+}